@@ -0,0 +1,87 @@
+package main
+
+import "encoding/xml"
+
+// Atom is an Atom 1.0 feed document.
+type Atom struct {
+	XMLName  xml.Name     `xml:"feed"`
+	Title    string       `xml:"title"`
+	Subtitle string       `xml:"subtitle"`
+	Links    []AtomLink   `xml:"link"`
+	Authors  []AtomPerson `xml:"author"`
+	Entries  []AtomEntry  `xml:"entry"`
+}
+
+type AtomEntry struct {
+	ID        string       `xml:"id"`
+	Title     string       `xml:"title"`
+	Summary   string       `xml:"summary"`
+	Content   string       `xml:"content"`
+	Published string       `xml:"published"`
+	Updated   string       `xml:"updated"`
+	Links     []AtomLink   `xml:"link"`
+	Authors   []AtomPerson `xml:"author"`
+	// VideoID is set on entries of YouTube channel feeds
+	VideoID string `xml:"http://www.youtube.com/xml/schemas/2015 videoId"`
+	Media
+}
+
+type AtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+// toRSS normalizes an Atom feed into the RSS representation used by rssToICal.
+func (a *Atom) toRSS() *RSS {
+	rss := &RSS{
+		Channel: Channel{
+			Title:       a.Title,
+			Description: a.Subtitle,
+			Links:       a.Links,
+			Link:        alternateLink(a.Links),
+			Author:      firstAuthor(a.Authors),
+		},
+	}
+
+	for _, entry := range a.Entries {
+		description := entry.Summary
+		if description == "" {
+			description = entry.Content
+		}
+
+		pubDate := entry.Published
+		if pubDate == "" {
+			pubDate = entry.Updated
+		}
+
+		item := Item{
+			Title:          entry.Title,
+			Description:    description,
+			ContentEncoded: entry.Content,
+			Link:           alternateLink(entry.Links),
+			PubDate:        pubDate,
+			Updated:        entry.Updated,
+			GUID:           entry.ID,
+			Author:         firstAuthor(entry.Authors),
+			Media:          entry.Media,
+		}
+		if entry.VideoID != "" {
+			item = entry.youtubeItem(item)
+		}
+		rss.Channel.Items = append(rss.Channel.Items, item)
+	}
+
+	return rss
+}
+
+// alternateLink returns the first rel="alternate" link of an entry or feed.
+// A link without a rel attribute is an alternate link per RFC 4287.
+func alternateLink(links []AtomLink) string {
+	for _, link := range links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			return link.Href
+		}
+	}
+	return ""
+}
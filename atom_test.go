@@ -0,0 +1,140 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Mock Atom feed for testing
+const mockAtomFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Test Atom Feed</title>
+  <subtitle>Test Atom Subtitle</subtitle>
+  <entry>
+    <id>urn:uuid:atom-entry-1</id>
+    <title>Atom Entry 1</title>
+    <summary>Atom Summary 1</summary>
+    <link rel="self" href="https://example.com/atom/1.xml"/>
+    <link rel="alternate" href="https://example.com/atom/1"/>
+    <published>2025-07-27T12:00:00Z</published>
+    <updated>2025-07-28T12:00:00Z</updated>
+  </entry>
+  <entry>
+    <id>urn:uuid:atom-entry-2</id>
+    <title>Atom Entry 2</title>
+    <content>Atom Content 2</content>
+    <link href="https://example.com/atom/2"/>
+    <updated>2025-07-27T13:00:00Z</updated>
+  </entry>
+</feed>`
+
+func TestParseRSSAtom(t *testing.T) {
+	rss, err := parseRSS([]byte(mockAtomFeed))
+	if err != nil {
+		t.Fatalf("Failed to parse Atom feed: %v", err)
+	}
+
+	if rss.Channel.Title != "Test Atom Feed" {
+		t.Errorf("Expected channel title 'Test Atom Feed', got '%s'", rss.Channel.Title)
+	}
+
+	if len(rss.Channel.Items) != 2 {
+		t.Fatalf("Expected 2 items, got %d", len(rss.Channel.Items))
+	}
+
+	first := rss.Channel.Items[0]
+	if first.Link != "https://example.com/atom/1" {
+		t.Errorf("Expected alternate link, got '%s'", first.Link)
+	}
+	if first.PubDate != "2025-07-27T12:00:00Z" {
+		t.Errorf("Expected published date, got '%s'", first.PubDate)
+	}
+
+	second := rss.Channel.Items[1]
+	if second.Description != "Atom Content 2" {
+		t.Errorf("Expected content fallback for description, got '%s'", second.Description)
+	}
+	if second.PubDate != "2025-07-27T13:00:00Z" {
+		t.Errorf("Expected updated date fallback, got '%s'", second.PubDate)
+	}
+}
+
+func TestAtomToICal(t *testing.T) {
+	rss, err := parseRSS([]byte(mockAtomFeed))
+	if err != nil {
+		t.Fatalf("Failed to parse Atom feed: %v", err)
+	}
+
+	ical, err := rssToICal(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert Atom to iCal: %v", err)
+	}
+
+	expected := []string{
+		"NAME:Test Atom Feed",
+		"UID:urn:uuid:atom-entry-1",
+		"SUMMARY:Atom Entry 1",
+		"URL:https://example.com/atom/1",
+		"DTSTART:20250727T120000Z",
+		"UID:urn:uuid:atom-entry-2",
+	}
+
+	for _, exp := range expected {
+		if !strings.Contains(ical, exp) {
+			t.Errorf("Expected iCal to contain '%s', but it didn't", exp)
+		}
+	}
+}
+
+func TestParseRSSUnsupportedFormat(t *testing.T) {
+	if _, err := parseRSS([]byte(`<html><body>Not a feed</body></html>`)); err == nil {
+		t.Error("Expected error for unsupported root element")
+	}
+}
+
+func TestCalendarHandlerAtomFeed(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/atom+xml")
+		w.Write([]byte(mockAtomFeed))
+	}))
+	defer mockServer.Close()
+
+	// Clear cache for clean test
+	cache = &Cache{}
+
+	req := httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil)
+	w := httptest.NewRecorder()
+
+	calendarHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code 200, got %d", w.Code)
+	}
+
+	if body := w.Body.String(); !strings.Contains(body, "UID:urn:uuid:atom-entry-1") {
+		t.Errorf("Expected Atom entry UID in iCalendar, got: %s", body)
+	}
+}
+
+func TestAtomUpdatedLastModified(t *testing.T) {
+	rss, err := parseRSS([]byte(mockAtomFeed))
+	if err != nil {
+		t.Fatalf("Failed to parse Atom feed: %v", err)
+	}
+
+	ical, err := rssToICal(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert Atom to iCal: %v", err)
+	}
+
+	events := strings.Split(ical, "BEGIN:VEVENT")[1:]
+	if !strings.Contains(events[0], "CREATED:20250727T120000Z") || !strings.Contains(events[0], "LAST-MODIFIED:20250728T120000Z") {
+		t.Errorf("Expected CREATED from <published> and LAST-MODIFIED from <updated>, got: %s", events[0])
+	}
+	// An entry with only <updated> was created and modified at that time
+	if !strings.Contains(events[1], "CREATED:20250727T130000Z") || !strings.Contains(events[1], "LAST-MODIFIED:20250727T130000Z") {
+		t.Errorf("Expected CREATED and LAST-MODIFIED from <updated>, got: %s", events[1])
+	}
+}
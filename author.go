@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/mail"
+	"strings"
+	"unicode"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+// person is a feed author split into an email address and a display name,
+// either of which may be empty.
+type person struct {
+	email string
+	name  string
+}
+
+// parsePerson reads an author as feeds write it: RSS's "email (Name)",
+// "Name <email>", a bare address or just a name. Addresses that don't parse
+// are dropped, keeping only the name.
+func parsePerson(raw string) person {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return person{}
+	}
+	if address, err := mail.ParseAddress(raw); err == nil {
+		return person{email: address.Address, name: address.Name}
+	}
+	if open := strings.Index(raw, "("); open > 0 && strings.HasSuffix(raw, ")") {
+		email := strings.TrimSpace(raw[:open])
+		name := strings.TrimSpace(raw[open+1 : len(raw)-1])
+		if validEmail(email) {
+			return person{email: email, name: name}
+		}
+	}
+	// mail.ParseAddress wants names with commas quoted, which feeds rarely do
+	if open := strings.LastIndex(raw, "<"); open >= 0 && strings.HasSuffix(raw, ">") {
+		email := strings.TrimSpace(raw[open+1 : len(raw)-1])
+		if validEmail(email) {
+			return person{email: email, name: strings.Trim(strings.TrimSpace(raw[:open]), `"`)}
+		}
+	}
+	if strings.Contains(raw, "@") {
+		return person{}
+	}
+	return person{name: raw}
+}
+
+// validEmail reports whether s is a bare email address.
+func validEmail(s string) bool {
+	address, err := mail.ParseAddress(s)
+	return err == nil && address.Address == s
+}
+
+// contact returns who wrote the item, from <author> or <dc:creator>, as
+// "Name <email>", a name or an address.
+func (i Item) contact() string {
+	for _, raw := range []string{i.Author, i.DCCreator} {
+		p := parsePerson(raw)
+		switch {
+		case p.name != "" && p.email != "":
+			return p.name + " <" + p.email + ">"
+		case p.name != "":
+			return p.name
+		case p.email != "":
+			return p.email
+		}
+	}
+	return ""
+}
+
+// organizer returns who an event is organized by: the item's author when
+// it gives an email address, otherwise the channel's managing editor or
+// author. Without any address the event has no ORGANIZER.
+func organizer(item Item, channel Channel) person {
+	for _, raw := range []string{item.Author, channel.ManagingEditor, channel.Author} {
+		if p := parsePerson(raw); p.email != "" {
+			return p
+		}
+	}
+	return person{}
+}
+
+// setOrganizer sets the event's ORGANIZER to p, with its name as CN.
+func setOrganizer(event *ics.VEvent, p person) {
+	if p.email == "" {
+		return
+	}
+	var params []ics.PropertyParameter
+	// Names such as "Doe, Jane" would need a quoted parameter value, which
+	// golang-ical backslash-escapes instead, so those are left out
+	if p.name != "" && !strings.ContainsAny(p.name, "\";:,") && strings.IndexFunc(p.name, unicode.IsControl) < 0 {
+		params = append(params, ics.WithCN(p.name))
+	}
+	event.SetOrganizer("mailto:"+p.email, params...)
+}
+
+// AtomPerson is an Atom <author>.
+type AtomPerson struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email"`
+}
+
+// String formats the author the way RSS does, "email (Name)".
+func (p AtomPerson) String() string {
+	name, email := strings.TrimSpace(p.Name), strings.TrimSpace(p.Email)
+	switch {
+	case email == "":
+		return name
+	case name == "":
+		return email
+	default:
+		return email + " (" + name + ")"
+	}
+}
+
+// firstAuthor returns the first of authors as an RSS author string.
+func firstAuthor(authors []AtomPerson) string {
+	if len(authors) == 0 {
+		return ""
+	}
+	return authors[0].String()
+}
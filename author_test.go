@@ -0,0 +1,59 @@
+package main
+
+import "testing"
+
+func TestParsePerson(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want person
+	}{
+		{"editor@example.com (Jane Doe)", person{email: "editor@example.com", name: "Jane Doe"}},
+		{"Jane Doe <editor@example.com>", person{email: "editor@example.com", name: "Jane Doe"}},
+		{"Doe, Jane <editor@example.com>", person{email: "editor@example.com", name: "Doe, Jane"}},
+		{" editor@example.com ", person{email: "editor@example.com"}},
+		{"Jane Doe", person{name: "Jane Doe"}},
+		{"not-an-address@ (Jane Doe)", person{}},
+		{"broken@", person{}},
+		{"", person{}},
+	}
+	for _, tt := range tests {
+		if got := parsePerson(tt.raw); got != tt.want {
+			t.Errorf("parsePerson(%q) = %+v, want %+v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestOrganizer(t *testing.T) {
+	channel := Channel{ManagingEditor: "editor@example.com (Editor)", Author: "owner@example.com"}
+	tests := []struct {
+		item    Item
+		channel Channel
+		want    string
+	}{
+		{Item{Author: "writer@example.com (Writer)"}, channel, "writer@example.com"},
+		{Item{Author: "Writer"}, channel, "editor@example.com"},
+		{Item{}, Channel{Author: "owner@example.com"}, "owner@example.com"},
+		{Item{Author: "Writer"}, Channel{ManagingEditor: "Editor"}, ""},
+	}
+	for _, tt := range tests {
+		if got := organizer(tt.item, tt.channel); got.email != tt.want {
+			t.Errorf("organizer(%+v, %+v) = %q, want %q", tt.item, tt.channel, got.email, tt.want)
+		}
+	}
+}
+
+func TestAtomPersonString(t *testing.T) {
+	tests := []struct {
+		person AtomPerson
+		want   string
+	}{
+		{AtomPerson{Name: "Jane Doe", Email: "jane@example.com"}, "jane@example.com (Jane Doe)"},
+		{AtomPerson{Name: "Jane Doe"}, "Jane Doe"},
+		{AtomPerson{Email: "jane@example.com"}, "jane@example.com"},
+	}
+	for _, tt := range tests {
+		if got := tt.person.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.person, got, tt.want)
+		}
+	}
+}
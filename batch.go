@@ -0,0 +1,225 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	// maxBatchFeeds caps how many feeds one /batch request may export.
+	maxBatchFeeds = 50
+	// batchWorkers is how many feeds of a batch are converted at once.
+	batchWorkers = 4
+	// maxBatchBodyBytes caps the size of a /batch request body.
+	maxBatchBodyBytes = 64 << 10
+	// maxCalendarFileName caps the length of a file name derived from a
+	// feed title, before the .ics extension.
+	maxCalendarFileName = 100
+)
+
+// batchCalendar is one feed's calendar in a /batch export.
+type batchCalendar struct {
+	title string
+	data  string
+	err   error
+}
+
+// batchHandler exports the calendars of a JSON array of feed URLs as a zip
+// archive with one .ics file per feed, named after the feed's title. Feeds
+// that fail are listed in the X-Failed-Feeds header and left out.
+func batchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var raw []string
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodyBytes)).Decode(&raw); err != nil {
+		http.Error(w, "Invalid body: expected a JSON array of feed URLs", http.StatusBadRequest)
+		return
+	}
+	if len(raw) == 0 {
+		http.Error(w, "At least one feed URL is required", http.StatusBadRequest)
+		return
+	}
+	if len(raw) > maxBatchFeeds {
+		http.Error(w, fmt.Sprintf("At most %d feeds can be exported at once", maxBatchFeeds), http.StatusBadRequest)
+		return
+	}
+
+	urls := make([]string, len(raw))
+	for i, rawURL := range raw {
+		normalized, err := normalizeFeedURL(rawURL)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !hostAllowed(normalized) {
+			http.Error(w, "Feed host not allowed", http.StatusForbidden)
+			return
+		}
+		urls[i] = normalized
+	}
+
+	calendars := convertBatch(r.Context(), urls)
+	if r.Context().Err() != nil {
+		// The client is gone, so there's no one to answer
+		return
+	}
+	var failed []string
+	for i, calendar := range calendars {
+		if calendar.err != nil {
+			loggerFrom(r.Context()).Error("batch feed failed", "url", redactURL(urls[i]), "error", calendar.err)
+			failed = append(failed, redactURL(urls[i]))
+		}
+	}
+	if len(failed) == len(urls) {
+		http.Error(w, "Failed to fetch RSS feeds", http.StatusBadGateway)
+		return
+	}
+
+	if len(failed) > 0 {
+		w.Header().Set("X-Failed-Feeds", strings.Join(failed, ", "))
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="calendars.zip"`)
+
+	archive := zip.NewWriter(w)
+	names := make(map[string]int)
+	now := time.Now()
+	for i, calendar := range calendars {
+		if calendar.err != nil {
+			continue
+		}
+		file, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     uniqueFileName(names, calendarFileName(calendar.title, urls[i])),
+			Method:   zip.Deflate,
+			Modified: now,
+		})
+		if err == nil {
+			_, err = file.Write([]byte(calendar.data))
+		}
+		if err != nil {
+			// Headers are sent, so the client sees a truncated archive
+			loggerFrom(r.Context()).Error("batch archive write failed", "error", err)
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		loggerFrom(r.Context()).Error("batch archive write failed", "error", err)
+	}
+}
+
+// convertBatch fetches and converts each feed with the options /calendar
+// would use for it by default. Up to batchWorkers feeds are fetched at once.
+func convertBatch(ctx context.Context, urls []string) []batchCalendar {
+	calendars := make([]batchCalendar, len(urls))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < batchWorkers && worker < len(urls); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				calendars[i] = convertBatchFeed(ctx, urls[i])
+			}
+		}()
+	}
+	for i := range urls {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return calendars
+}
+
+func convertBatchFeed(ctx context.Context, feedURL string) batchCalendar {
+	query := url.Values{"url": {feedURL}}
+	applyFeedDefaults(query, []string{feedURL})
+	opts, err := parseCalendarOptions(query)
+	if err != nil {
+		return batchCalendar{err: err}
+	}
+
+	if failures.Failed(feedURL) {
+		return batchCalendar{err: errRecentFailure}
+	}
+	rss, err := fetchRSS(ctx, feedURL)
+	if errors.Is(err, errFetchBusy) || (err != nil && fetchAbandoned(ctx, err)) {
+		// The feed itself didn't fail, so it isn't remembered
+		return batchCalendar{err: err}
+	}
+	if err != nil {
+		failures.Remember(feedURL)
+		return batchCalendar{err: err}
+	}
+	failures.Forget(feedURL)
+
+	data, err := rssToICal(rss, opts)
+	if err != nil {
+		return batchCalendar{err: fmt.Errorf("failed to convert to iCalendar: %w", err)}
+	}
+	return batchCalendar{title: rss.Channel.Title, data: data}
+}
+
+// calendarFileName turns a feed title into a safe .ics file name, keeping
+// letters, digits, spaces and "-_." and falling back to the feed's host.
+func calendarFileName(title, feedURL string) string {
+	name := sanitizeFileName(title)
+	if name == "" {
+		if parsed, err := url.Parse(feedURL); err == nil {
+			name = sanitizeFileName(parsed.Hostname())
+		}
+	}
+	if name == "" {
+		name = "calendar"
+	}
+	return name + ".ics"
+}
+
+func sanitizeFileName(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("-_.", r):
+			b.WriteRune(r)
+		case unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r):
+			b.WriteRune(' ')
+		}
+	}
+	name := strings.Join(strings.Fields(b.String()), " ")
+	if runes := []rune(name); len(runes) > maxCalendarFileName {
+		name = string(runes[:maxCalendarFileName])
+	}
+	// Leading dots would make hidden files or "..", trailing ones an odd
+	// extension
+	return strings.Trim(name, ". ")
+}
+
+// uniqueFileName returns name, numbered "name-2.ics" and so on when
+// several feeds share a title.
+func uniqueFileName(seen map[string]int, name string) string {
+	seen[name]++
+	if seen[name] == 1 {
+		return name
+	}
+	base := strings.TrimSuffix(name, ".ics")
+	for n := seen[name]; ; n++ {
+		candidate := fmt.Sprintf("%s-%d.ics", base, n)
+		if seen[candidate] == 0 {
+			seen[candidate]++
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func batchRequest(t *testing.T, urls []string) *httptest.ResponseRecorder {
+	t.Helper()
+	body, err := json.Marshal(urls)
+	if err != nil {
+		t.Fatalf("Failed to encode URLs: %v", err)
+	}
+	req := httptest.NewRequest("POST", "/batch", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	batchHandler(w, req)
+	return w
+}
+
+func TestBatchHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/one.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(mockRSSFeed))
+	})
+	mux.HandleFunc("/two.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(`<?xml version="1.0"?><rss version="2.0"><channel><title>Team / Events: 2024</title>
+<item><title>Standup</title><guid>standup</guid><pubDate>Mon, 01 Jan 2024 09:00:00 GMT</pubDate></item>
+</channel></rss>`))
+	})
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+	failures = &NegativeCache{}
+
+	w := batchRequest(t, []string{mockServer.URL + "/one.xml", mockServer.URL + "/two.xml"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/zip" {
+		t.Errorf("Expected Content-Type application/zip, got %q", ct)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("Failed to read zip: %v", err)
+	}
+	files := map[string]string{}
+	for _, file := range archive.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", file.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[file.Name] = string(data)
+	}
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "Team Events 2024.ics,Test RSS Feed.ics" {
+		t.Fatalf("Expected two calendars named after the feeds, got %v", names)
+	}
+	if !strings.Contains(files["Team Events 2024.ics"], "SUMMARY:Standup") {
+		t.Errorf("Expected the second feed's events, got:\n%s", files["Team Events 2024.ics"])
+	}
+	if !strings.HasPrefix(files["Test RSS Feed.ics"], "BEGIN:VCALENDAR") {
+		t.Errorf("Expected an iCalendar file, got:\n%s", files["Test RSS Feed.ics"])
+	}
+}
+
+func TestBatchHandlerPartialFailure(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(mockRSSFeed))
+	})
+	mux.HandleFunc("/missing.xml", http.NotFound)
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+	failures = &NegativeCache{}
+
+	w := batchRequest(t, []string{mockServer.URL + "/ok.xml", mockServer.URL + "/missing.xml"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if failed := w.Header().Get("X-Failed-Feeds"); failed != mockServer.URL+"/missing.xml" {
+		t.Errorf("Expected the failed feed in X-Failed-Feeds, got %q", failed)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("Failed to read zip: %v", err)
+	}
+	if len(archive.File) != 1 {
+		t.Errorf("Expected one calendar, got %d", len(archive.File))
+	}
+
+	failures = &NegativeCache{}
+	if w := batchRequest(t, []string{mockServer.URL + "/missing.xml"}); w.Code != http.StatusBadGateway {
+		t.Errorf("Expected status 502 when every feed fails, got %d", w.Code)
+	}
+}
+
+func TestBatchHandlerFetchBusy(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected no upstream request while all fetch slots are taken")
+	}))
+	defer mockServer.Close()
+
+	defer func(slots chan struct{}) { fetchSlots = slots }(fetchSlots)
+	fetchSlots = make(chan struct{}, 1)
+	fetchSlots <- struct{}{}
+	defer func(wait time.Duration) { fetchSlotWait = wait }(fetchSlotWait)
+	fetchSlotWait = 10 * time.Millisecond
+	failures = &NegativeCache{}
+
+	batchRequest(t, []string{mockServer.URL + "/feed.xml"})
+	if failures.Failed(mockServer.URL + "/feed.xml") {
+		t.Error("Expected a busy fetch not to be remembered as a failure")
+	}
+}
+
+func TestBatchHandlerInvalid(t *testing.T) {
+	req := httptest.NewRequest("GET", "/batch", nil)
+	w := httptest.NewRecorder()
+	batchHandler(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for GET, got %d", w.Code)
+	}
+
+	for _, body := range []string{`{"url": "https://example.com"}`, `[]`, `["ftp://example.com/feed"]`} {
+		req := httptest.NewRequest("POST", "/batch", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		batchHandler(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", body, w.Code)
+		}
+	}
+
+	urls := make([]string, maxBatchFeeds+1)
+	for i := range urls {
+		urls[i] = "https://example.com/feed.xml"
+	}
+	if w := batchRequest(t, urls); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for too many feeds, got %d", w.Code)
+	}
+}
+
+func TestCalendarFileName(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Test RSS Feed", "Test RSS Feed.ics"},
+		{"../../etc/passwd", "etc passwd.ics"},
+		{"Café & Bar: Events", "Café Bar Events.ics"},
+		{"", "example.com.ics"},
+		{"???", "example.com.ics"},
+	}
+	for _, tt := range tests {
+		if got := calendarFileName(tt.title, "https://example.com/feed.xml"); got != tt.want {
+			t.Errorf("calendarFileName(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+
+	seen := map[string]int{}
+	for _, want := range []string{"Blog.ics", "Blog-2.ics", "Blog-3.ics"} {
+		if got := uniqueFileName(seen, "Blog.ics"); got != want {
+			t.Errorf("uniqueFileName = %q, want %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"container/list"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// cacheTTL is how long converted calendars are served from cache. It is
+// configured from the CACHE_TTL environment variable at startup.
+var cacheTTL = defaultCacheTTL
+
+// cacheMaxEntries bounds the number of cached calendars; the least recently
+// used entry is evicted once it is exceeded. It is configured from the
+// CACHE_MAX_ENTRIES environment variable at startup.
+var cacheMaxEntries = defaultCacheMaxEntries
+
+// maxFeedTTL caps the cache lifetime a feed can request through <ttl>.
+const maxFeedTTL = 24 * time.Hour
+
+type CacheEntry struct {
+	data      string
+	timestamp time.Time
+	// generated is when data was produced; revalidating the entry upstream
+	// refreshes timestamp but leaves generated alone
+	generated time.Time
+	// etag is the entity tag of data, sent to clients for revalidation
+	etag       string
+	validators Validators
+	// versions tracks the calendar's events so changes bump their SEQUENCE
+	versions eventVersions
+	// events counts the calendar's events, reported in X-Event-Count
+	events int
+	// skipped counts feed items left out because their event couldn't be
+	// built, reported in X-Skipped-Items
+	skipped int
+	// ttl overrides cacheTTL for this entry when positive
+	ttl time.Duration
+}
+
+func (e CacheEntry) expired() bool {
+	ttl := e.ttl
+	if ttl <= 0 {
+		ttl = cacheTTL
+	}
+	return time.Since(e.timestamp) > ttl
+}
+
+// Validators are the upstream ETag and Last-Modified values used to make
+// conditional requests for a feed that has already been fetched.
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+// CacheBackend is shared storage for cached calendars, so replicas behind a
+// load balancer serve each other's conversions. Get returns entries even if
+// they have expired, like Cache.Lookup.
+type CacheBackend interface {
+	Get(key string) (CacheEntry, bool, error)
+	Set(key string, entry CacheEntry) error
+	// Delete removes the entries whose feeds match, returning how many.
+	// match is given keyFeeds of each entry's key.
+	Delete(match func(feeds string) bool) (int, error)
+}
+
+// Cache holds converted calendars by cache key. Entries are kept in memory
+// unless a backend is set, in which case every operation goes to it and
+// the in-memory entries and cacheMaxEntries are unused.
+type Cache struct {
+	backend CacheBackend
+
+	entries map[string]CacheEntry
+	// recency orders keys from most to least recently used
+	recency  *list.List
+	elements map[string]*list.Element
+	mu       sync.RWMutex
+
+	// hits and misses count Fresh lookups
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// CacheStats summarizes the cache for /cache/stats. Ages are in seconds
+// since an entry was stored or last revalidated.
+type CacheStats struct {
+	Entries   int     `json:"entries"`
+	Hits      int64   `json:"hits"`
+	Misses    int64   `json:"misses"`
+	OldestAge float64 `json:"oldest_age_seconds"`
+	NewestAge float64 `json:"newest_age_seconds"`
+}
+
+func (c *Cache) Get(url string) (string, bool) {
+	entry, ok := c.Fresh(url)
+	return entry.data, ok
+}
+
+// Fresh returns the entry for url if it has not expired.
+func (c *Cache) Fresh(url string) (CacheEntry, bool) {
+	if c.backend != nil {
+		entry, exists := c.sharedEntry(url)
+		if !exists || entry.expired() {
+			c.misses.Add(1)
+			return CacheEntry{}, false
+		}
+		c.hits.Add(1)
+		return entry, true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, exists := c.entries[url]
+	if !exists || entry.expired() {
+		c.misses.Add(1)
+		return CacheEntry{}, false
+	}
+	c.hits.Add(1)
+	c.markUsed(url)
+	return entry, true
+}
+
+// Stats reports the entry count, lookup counters and entry ages. Entries
+// held by a backend aren't counted.
+func (c *Cache) Stats() CacheStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	stats := CacheStats{
+		Entries: len(c.entries),
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+	}
+	now := time.Now()
+	first := true
+	for _, entry := range c.entries {
+		age := now.Sub(entry.timestamp).Seconds()
+		if first || age > stats.OldestAge {
+			stats.OldestAge = age
+		}
+		if first || age < stats.NewestAge {
+			stats.NewestAge = age
+		}
+		first = false
+	}
+	return stats
+}
+
+// Lookup returns the entry for url even if it has expired, so its
+// validators can be used to revalidate it upstream.
+func (c *Cache) Lookup(url string) (CacheEntry, bool) {
+	if c.backend != nil {
+		return c.sharedEntry(url)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, exists := c.entries[url]
+	return entry, exists
+}
+
+func (c *Cache) Set(url, data string) {
+	c.Store(url, CacheEntry{data: data})
+}
+
+// Store caches entry under url, stamping it with the current time. The
+// generation time defaults to now as well.
+func (c *Cache) Store(url string, entry CacheEntry) {
+	entry.timestamp = time.Now()
+	if entry.generated.IsZero() {
+		entry.generated = entry.timestamp
+	}
+	if c.backend != nil {
+		c.storeShared(url, entry)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]CacheEntry)
+	}
+	c.entries[url] = entry
+	c.markUsed(url)
+	c.evict()
+}
+
+// Touch marks an existing entry as fresh again without changing its data.
+func (c *Cache) Touch(url string) {
+	if c.backend != nil {
+		if entry, exists := c.sharedEntry(url); exists {
+			entry.timestamp = time.Now()
+			c.storeShared(url, entry)
+		}
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, exists := c.entries[url]; exists {
+		entry.timestamp = time.Now()
+		c.entries[url] = entry
+		c.markUsed(url)
+	}
+}
+
+// Delete removes every entry for feedURL, whatever options it was
+// converted with, including merged calendars that contain it. It returns
+// how many entries were removed.
+func (c *Cache) Delete(feedURL string) int {
+	if c.backend != nil {
+		return c.deleteShared(func(feeds string) bool { return keyHasFeed(feeds, redactURL(feedURL)) })
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for key := range c.entries {
+		if keyHasFeed(key, feedURL) {
+			c.remove(key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// keyHasFeed reports whether the calendar cached under key includes feedURL.
+func keyHasFeed(key, feedURL string) bool {
+	feeds, _, _ := strings.Cut(key, "#")
+	for _, url := range feedURLs([]string{feeds}) {
+		if url == feedURL {
+			return true
+		}
+	}
+	return false
+}
+
+// keyFeeds returns the feed URLs of a cache key, without its options and
+// with passwords redacted, for backends to keep in the clear beside an entry.
+// keyHasFeed matches them against a feed URL redacted the same way.
+func keyFeeds(key string) string {
+	feeds, _, _ := strings.Cut(key, "#")
+	urls := feedURLs([]string{feeds})
+	for i, url := range urls {
+		urls[i] = redactURL(url)
+	}
+	return strings.Join(urls, ",")
+}
+
+// Clear removes all entries, returning how many there were.
+func (c *Cache) Clear() int {
+	if c.backend != nil {
+		return c.deleteShared(func(string) bool { return true })
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := len(c.entries)
+	c.entries = nil
+	c.recency = nil
+	c.elements = nil
+	return removed
+}
+
+// sharedEntry reads key from the backend. Errors are logged and treated as
+// a miss, so an unreachable backend only costs upstream fetches.
+func (c *Cache) sharedEntry(key string) (CacheEntry, bool) {
+	entry, exists, err := c.backend.Get(key)
+	if err != nil {
+		slog.Warn("cache backend read failed", "error", err)
+		return CacheEntry{}, false
+	}
+	return entry, exists
+}
+
+func (c *Cache) storeShared(key string, entry CacheEntry) {
+	if err := c.backend.Set(key, entry); err != nil {
+		slog.Warn("cache backend write failed", "error", err)
+	}
+}
+
+func (c *Cache) deleteShared(match func(feeds string) bool) int {
+	removed, err := c.backend.Delete(match)
+	if err != nil {
+		slog.Warn("cache backend delete failed", "error", err)
+	}
+	return removed
+}
+
+// remove drops the entry for key. The caller must hold the write lock.
+func (c *Cache) remove(key string) {
+	if elem, exists := c.elements[key]; exists {
+		c.recency.Remove(elem)
+		delete(c.elements, key)
+	}
+	delete(c.entries, key)
+}
+
+// markUsed moves url to the front of the recency list. The caller must hold
+// the write lock.
+func (c *Cache) markUsed(url string) {
+	if c.recency == nil {
+		c.recency = list.New()
+		c.elements = make(map[string]*list.Element)
+	}
+
+	if elem, exists := c.elements[url]; exists {
+		c.recency.MoveToFront(elem)
+		return
+	}
+	c.elements[url] = c.recency.PushFront(url)
+}
+
+// evict drops least recently used entries until the cache is within
+// cacheMaxEntries. The caller must hold the write lock.
+func (c *Cache) evict() {
+	for len(c.entries) > cacheMaxEntries {
+		oldest := c.recency.Back()
+		if oldest == nil {
+			return
+		}
+		c.remove(oldest.Value.(string))
+	}
+}
+
+var cache = &Cache{}
+
+// negativeCacheTTL is how long a failed feed fetch is remembered, during
+// which requests for that feed fail fast instead of re-fetching it.
+var negativeCacheTTL = defaultNegativeCacheTTL
+
+// errRecentFailure is returned instead of fetching a feed that is in the
+// negative cache.
+var errRecentFailure = errors.New("feed recently failed to fetch")
+
+// NegativeCache remembers feed URLs whose last fetch failed. It is kept
+// apart from Cache so a failure never displaces a good calendar.
+type NegativeCache struct {
+	failures map[string]time.Time
+	mu       sync.Mutex
+}
+
+// Failed reports whether url failed within the last negativeCacheTTL.
+func (n *NegativeCache) Failed(url string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	failedAt, exists := n.failures[url]
+	if !exists {
+		return false
+	}
+	if time.Since(failedAt) > negativeCacheTTL {
+		delete(n.failures, url)
+		return false
+	}
+	return true
+}
+
+// Remember records a failed fetch of url.
+func (n *NegativeCache) Remember(url string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.failures == nil {
+		n.failures = make(map[string]time.Time)
+	}
+	if _, exists := n.failures[url]; !exists && len(n.failures) >= cacheMaxEntries {
+		n.prune()
+	}
+	n.failures[url] = time.Now()
+}
+
+// prune makes room for another failure, bounding the map like the calendar
+// cache: expired failures are dropped, then the oldest ones while there are
+// still cacheMaxEntries. The caller must hold the lock.
+func (n *NegativeCache) prune() {
+	for url, failedAt := range n.failures {
+		if time.Since(failedAt) > negativeCacheTTL {
+			delete(n.failures, url)
+		}
+	}
+	for len(n.failures) >= cacheMaxEntries && len(n.failures) > 0 {
+		var oldest string
+		var oldestAt time.Time
+		for url, failedAt := range n.failures {
+			if oldestAt.IsZero() || failedAt.Before(oldestAt) {
+				oldest, oldestAt = url, failedAt
+			}
+		}
+		delete(n.failures, oldest)
+	}
+}
+
+// Forget clears a recorded failure after a successful fetch of url.
+func (n *NegativeCache) Forget(url string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	delete(n.failures, url)
+}
+
+var failures = &NegativeCache{}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// stdin is where a source of "-" is read from.
+var stdin io.Reader = os.Stdin
+
+// convertFeed loads the feed named by source and writes it as iCalendar to
+// w, for one-off conversions without the server.
+func convertFeed(source string, w io.Writer) error {
+	rss, err := loadFeed(source)
+	if err != nil {
+		return err
+	}
+
+	cal, err := buildCalendar(rss, defaultCalendarOptions())
+	if err != nil {
+		return fmt.Errorf("failed to convert to iCalendar: %w", err)
+	}
+
+	buffered := bufio.NewWriter(w)
+	if err := serializeCalendar(cal, buffered); err != nil {
+		return err
+	}
+	return buffered.Flush()
+}
+
+// loadFeed reads a feed from stdin when source is "-", from a local file
+// when source names one, and otherwise fetches it as a URL.
+func loadFeed(source string) (*RSS, error) {
+	var data []byte
+	var err error
+	switch {
+	case source == "-":
+		data, err = io.ReadAll(stdin)
+	case isLocalFile(source):
+		data, err = os.ReadFile(source)
+	default:
+		return fetchURL(source)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed: %w", err)
+	}
+	return parseRSS(data)
+}
+
+// isLocalFile reports whether source is a path to an existing file rather
+// than a URL, which may also be given without a scheme.
+func isLocalFile(source string) bool {
+	if strings.Contains(source, "://") {
+		return false
+	}
+	info, err := os.Stat(source)
+	return err == nil && !info.IsDir()
+}
+
+// fetchURL fetches a feed from the network, applying the same URL checks as
+// the server.
+func fetchURL(rawURL string) (*RSS, error) {
+	feedURL, err := normalizeFeedURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if !hostAllowed(feedURL) {
+		return nil, fmt.Errorf("feed host not allowed: %s", redactURL(feedURL))
+	}
+	return fetchRSS(context.Background(), feedURL)
+}
+
+// convertFeedTo runs convertFeed, writing to the file at out or to stdout
+// when out is empty. The calendar is written to a temporary file beside out
+// and renamed over it once converted, so a failed run, say a cron job hitting
+// a transient upstream error, leaves the last good file in place.
+func convertFeedTo(source, out string) error {
+	if out == "" {
+		return convertFeed(source, os.Stdout)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(out), "."+filepath.Base(out)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", out, err)
+	}
+	defer os.Remove(f.Name())
+
+	// The temporary file is private; give the calendar the mode it had, or
+	// the usual one for a new file
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(out); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to create %s: %w", out, err)
+	}
+	if err := convertFeed(source, f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", out, err)
+	}
+	if err := os.Rename(f.Name(), out); err != nil {
+		return fmt.Errorf("failed to write %s: %w", out, err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	defer func(p []netip.Prefix) { trustedProxies = p }(trustedProxies)
+	var err error
+	trustedProxies, err = parseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1"})
+	if err != nil {
+		t.Fatalf("Failed to parse trusted proxies: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{"trusted proxy", "10.1.2.3:4567", "203.0.113.7", "203.0.113.7"},
+		{"trusted proxy chain", "10.1.2.3:4567", "198.51.100.9, 203.0.113.7, 192.0.2.1", "203.0.113.7"},
+		{"untrusted remote", "198.51.100.20:4567", "203.0.113.7", "198.51.100.20"},
+		{"trusted proxy without header", "192.0.2.1:4567", "", "192.0.2.1"},
+		{"malformed hop", "10.1.2.3:4567", "not-an-ip", "10.1.2.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/calendar", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := clientIP(req); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestClientIPIgnoresForwardedWithoutTrustedProxies(t *testing.T) {
+	req := httptest.NewRequest("GET", "/calendar", nil)
+	req.RemoteAddr = "198.51.100.20:4567"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	if got := clientIP(req); got != "198.51.100.20" {
+		t.Errorf("Expected the remote address, got %s", got)
+	}
+}
+
+func TestParseTrustedProxiesInvalid(t *testing.T) {
+	if _, err := parseTrustedProxies([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("Expected an error for an invalid CIDR range")
+	}
+}
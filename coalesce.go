@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// sharedFetch is an upstream fetch in flight for one or more callers.
+type sharedFetch struct {
+	done   chan struct{}
+	result *FetchResult
+	err    error
+	cancel context.CancelFunc
+	// waiters counts the callers still waiting, guarded by sharedFetchesMu
+	waiters int
+}
+
+var (
+	// sharedFetches coalesces identical in-flight feed fetches, by fetchKey.
+	sharedFetches   = make(map[string]*sharedFetch)
+	sharedFetchesMu sync.Mutex
+)
+
+// fetchFeedShared is fetchFeed for request handlers: concurrent callers
+// asking for the same feed with the same validators and upstream headers
+// share a single upstream fetch. Each caller stops waiting when its own ctx
+// is done; the fetch outlives a caller that gives up while others still
+// wait, but is cancelled when the last one does, freeing its fetch slot.
+func fetchFeedShared(ctx context.Context, url string, validators Validators) (*FetchResult, error) {
+	key := fetchKey(ctx, url, validators)
+
+	sharedFetchesMu.Lock()
+	fetch, ok := sharedFetches[key]
+	if !ok {
+		// The fetch keeps the first caller's values, such as its upstream
+		// headers, but is cancelled by its waiters rather than by that caller
+		fetchCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		fetch = &sharedFetch{done: make(chan struct{}), cancel: cancel}
+		sharedFetches[key] = fetch
+		go fetch.run(fetchCtx, key, url, validators)
+	}
+	fetch.waiters++
+	sharedFetchesMu.Unlock()
+
+	stop := context.AfterFunc(ctx, func() { fetch.leave(key) })
+	defer stop()
+
+	select {
+	case <-fetch.done:
+		return fetch.result, fetch.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("failed to fetch RSS: %w", ctx.Err())
+	}
+}
+
+func (f *sharedFetch) run(ctx context.Context, key, url string, validators Validators) {
+	f.result, f.err = fetchFeed(ctx, url, validators)
+
+	sharedFetchesMu.Lock()
+	if sharedFetches[key] == f {
+		delete(sharedFetches, key)
+	}
+	sharedFetchesMu.Unlock()
+	f.cancel()
+	close(f.done)
+}
+
+// leave is called when a waiting caller gives up, cancelling the fetch if
+// it was the last one. Later callers then start a fetch of their own rather
+// than joining the cancelled one.
+func (f *sharedFetch) leave(key string) {
+	sharedFetchesMu.Lock()
+	defer sharedFetchesMu.Unlock()
+	if f.waiters--; f.waiters > 0 {
+		return
+	}
+	if sharedFetches[key] == f {
+		delete(sharedFetches, key)
+	}
+	f.cancel()
+}
+
+// fetchAbandoned reports whether a fetch failed only because the caller
+// behind ctx went away, such as a client disconnecting. That says nothing
+// about the feed, so it is neither logged as a failure nor remembered.
+func fetchAbandoned(ctx context.Context, err error) bool {
+	return errors.Is(err, context.Canceled) || ctx.Err() != nil
+}
+
+// fetchKey identifies everything that shapes a fetch's result.
+func fetchKey(ctx context.Context, url string, validators Validators) string {
+	var key strings.Builder
+	key.WriteString(url)
+	key.WriteString("\n" + validators.ETag + "\n" + validators.LastModified)
+	if feedDiscovery(ctx) {
+		key.WriteString("\ndiscover")
+	}
+	if header := upstreamHeaders(ctx); header != nil {
+		key.WriteString("\n")
+		header.Write(&key)
+	}
+	return key.String()
+}
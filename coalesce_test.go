@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCalendarHandlerCoalescesFetches(t *testing.T) {
+	var calls int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(mockRSSFeed))
+	}))
+	defer mockServer.Close()
+
+	// Start from an expired entry
+	cache = &Cache{}
+	failures = &NegativeCache{}
+	cache.Store(mockServer.URL, CacheEntry{data: "stale", ttl: time.Nanosecond})
+	time.Sleep(time.Millisecond)
+
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			req := httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil)
+			w := httptest.NewRecorder()
+			calendarHandler(w, req)
+			if w.Code != http.StatusOK {
+				t.Errorf("Expected status code 200, got %d", w.Code)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("Expected 1 upstream fetch, got %d", n)
+	}
+}
+
+func TestFetchFeedSharedOutlivesOneCaller(t *testing.T) {
+	release := make(chan struct{})
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(mockRSSFeed))
+	}))
+	defer mockServer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := fetchFeedShared(ctx, mockServer.URL, Validators{})
+		first <- err
+	}()
+
+	second := make(chan error, 1)
+	go func() {
+		// Give the first caller time to start the shared fetch
+		time.Sleep(10 * time.Millisecond)
+		_, err := fetchFeedShared(context.Background(), mockServer.URL, Validators{})
+		second <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	if err := <-first; err == nil {
+		t.Error("Expected the cancelled caller to stop waiting with an error")
+	}
+
+	close(release)
+	if err := <-second; err != nil {
+		t.Errorf("Expected the other caller to get the shared result, got: %v", err)
+	}
+}
+
+func TestFetchFeedSharedCancelsWithLastCaller(t *testing.T) {
+	upstreamCancelled := make(chan struct{})
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		close(upstreamCancelled)
+	}))
+	defer mockServer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := fetchFeedShared(ctx, mockServer.URL, Validators{})
+		done <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	if err := <-done; err == nil {
+		t.Error("Expected the cancelled caller to stop waiting with an error")
+	}
+	select {
+	case <-upstreamCancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the upstream fetch to be cancelled once no caller waits")
+	}
+
+	// A later caller starts a fetch of its own instead of joining the
+	// cancelled one
+	sharedFetchesMu.Lock()
+	_, inFlight := sharedFetches[fetchKey(context.Background(), mockServer.URL, Validators{})]
+	sharedFetchesMu.Unlock()
+	if inFlight {
+		t.Error("Expected the cancelled fetch to no longer be shared")
+	}
+}
+
+func TestCalendarHandlerClientGoneNotRemembered(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer mockServer.Close()
+
+	cache = &Cache{}
+	failures = &NegativeCache{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	req := httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil).WithContext(ctx)
+	calendarHandler(httptest.NewRecorder(), req)
+
+	if failures.Failed(mockServer.URL) {
+		t.Error("Expected a fetch abandoned by the client not to be remembered as a failure")
+	}
+
+	// Nor when the client of a merged calendar goes away
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	fetchMerged(ctx, []string{mockServer.URL, mockServer.URL + "/other"}, 1)
+	if failures.Failed(mockServer.URL) || failures.Failed(mockServer.URL+"/other") {
+		t.Error("Expected merged fetches abandoned by the client not to be remembered as failures")
+	}
+}
+
+func TestFetchKey(t *testing.T) {
+	base := fetchKey(context.Background(), "https://test.com/rss.xml", Validators{})
+	if fetchKey(context.Background(), "https://test.com/rss.xml", Validators{ETag: `"v1"`}) == base {
+		t.Error("Expected validators to produce a distinct fetch key")
+	}
+
+	ctx := withUpstreamHeaders(context.Background(), http.Header{"X-Api-Key": {"secret"}})
+	if fetchKey(ctx, "https://test.com/rss.xml", Validators{}) == base {
+		t.Error("Expected upstream headers to produce a distinct fetch key")
+	}
+}
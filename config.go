@@ -0,0 +1,342 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the server settings. Values from a config file override the
+// defaults and are in turn overridden by environment variables; query
+// parameters still take precedence for per-request event options.
+type Config struct {
+	Port            string
+	CacheTTL        time.Duration
+	CacheMaxEntries int
+	// CacheBackend is "memory" or "redis"; Redis shares cached calendars
+	// between replicas.
+	CacheBackend string
+	// RedisURL locates the Redis server for the redis cache backend.
+	RedisURL string
+	// DefaultDuration is the event length used when a request has no
+	// duration parameter.
+	DefaultDuration time.Duration
+	// AllowedHosts, when non-empty, restricts which hosts feeds may be
+	// fetched from. Each entry also matches its subdomains.
+	AllowedHosts []string
+	// AllowPrivateNetworks permits feeds on loopback, private and
+	// link-local addresses.
+	AllowPrivateNetworks bool
+	// MaxRedirects is how many redirects a feed fetch follows.
+	MaxRedirects int
+	// NegativeCacheTTL is how long a failed feed fetch is remembered.
+	NegativeCacheTTL time.Duration
+	// FetchRetries is how many times a transiently failing fetch is retried.
+	FetchRetries int
+	// FetchTimeout bounds each upstream fetch attempt.
+	FetchTimeout time.Duration
+	// MaxFeedBytes caps the size of a fetched feed.
+	MaxFeedBytes int
+	// MaxItems caps how many items of a feed are converted.
+	MaxItems int
+	// AllowCookieHeader lets requests forward a Cookie header upstream.
+	AllowCookieHeader bool
+	// MaxConcurrentFetches caps how many upstream fetches run at once.
+	MaxConcurrentFetches int
+	// LogFormat is "text" or "json".
+	LogFormat string
+	// RateLimit caps requests per minute from each client IP; zero means
+	// no limit.
+	RateLimit int
+	// TrustedProxies lists the CIDR ranges or addresses of reverse proxies
+	// whose X-Forwarded-For header is believed.
+	TrustedProxies []string
+	// UserAgent replaces the default User-Agent sent upstream.
+	UserAgent string
+	// Feeds sets conversion defaults for feeds by URL pattern.
+	Feeds []FeedDefaults
+}
+
+// configFile is the on-disk form of Config, with durations written as
+// strings like "5m".
+type configFile struct {
+	Port            int      `json:"port" yaml:"port"`
+	CacheTTL        string   `json:"cache_ttl" yaml:"cache_ttl"`
+	CacheMaxEntries int      `json:"cache_max_entries" yaml:"cache_max_entries"`
+	DefaultDuration string   `json:"default_duration" yaml:"default_duration"`
+	AllowedHosts    []string `json:"allowed_hosts" yaml:"allowed_hosts"`
+
+	CacheBackend string `json:"cache_backend" yaml:"cache_backend"`
+	RedisURL     string `json:"redis_url" yaml:"redis_url"`
+
+	AllowPrivateNetworks bool `json:"allow_private_networks" yaml:"allow_private_networks"`
+	MaxRedirects         *int `json:"max_redirects" yaml:"max_redirects"`
+	FetchRetries         *int `json:"fetch_retries" yaml:"fetch_retries"`
+
+	NegativeCacheTTL string `json:"negative_cache_ttl" yaml:"negative_cache_ttl"`
+	FetchTimeout     string `json:"fetch_timeout" yaml:"fetch_timeout"`
+	MaxFeedBytes     int    `json:"max_feed_bytes" yaml:"max_feed_bytes"`
+	MaxItems         int    `json:"max_items" yaml:"max_items"`
+
+	AllowCookieHeader    bool `json:"allow_cookie_header" yaml:"allow_cookie_header"`
+	MaxConcurrentFetches int  `json:"max_concurrent_fetches" yaml:"max_concurrent_fetches"`
+
+	LogFormat string `json:"log_format" yaml:"log_format"`
+	RateLimit int    `json:"rate_limit" yaml:"rate_limit"`
+
+	TrustedProxies []string `json:"trusted_proxies" yaml:"trusted_proxies"`
+	UserAgent      string   `json:"user_agent" yaml:"user_agent"`
+
+	Feeds []feedDefaultsFile `json:"feeds" yaml:"feeds"`
+}
+
+func defaultConfig() Config {
+	return Config{
+		Port:            defaultPort,
+		CacheTTL:        defaultCacheTTL,
+		CacheMaxEntries: defaultCacheMaxEntries,
+		CacheBackend:    cacheBackendMemory,
+		DefaultDuration: defaultEventDuration,
+		MaxRedirects:    defaultMaxRedirects,
+
+		NegativeCacheTTL: defaultNegativeCacheTTL,
+		FetchRetries:     defaultFetchRetries,
+		FetchTimeout:     defaultFetchTimeout,
+		MaxFeedBytes:     defaultMaxFeedBytes,
+		MaxItems:         defaultMaxItems,
+
+		MaxConcurrentFetches: defaultMaxConcurrentFetches,
+		LogFormat:            logFormatText,
+	}
+}
+
+// LoadConfig reads the YAML or JSON config file at path on top of the
+// defaults. JSON is used for files ending in .json, YAML otherwise. An
+// empty path returns the defaults.
+func LoadConfig(path string) (Config, error) {
+	cfg := defaultConfig()
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var file configFile
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &file)
+	} else {
+		err = yaml.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	if file.Port != 0 {
+		cfg.Port = strconv.Itoa(file.Port)
+	}
+	if file.CacheTTL != "" {
+		if cfg.CacheTTL, err = time.ParseDuration(file.CacheTTL); err != nil {
+			return cfg, fmt.Errorf("invalid cache_ttl %q", file.CacheTTL)
+		}
+	}
+	if file.CacheMaxEntries != 0 {
+		cfg.CacheMaxEntries = file.CacheMaxEntries
+	}
+	if file.CacheBackend != "" {
+		cfg.CacheBackend = file.CacheBackend
+	}
+	if file.RedisURL != "" {
+		cfg.RedisURL = file.RedisURL
+	}
+	if file.DefaultDuration != "" {
+		if cfg.DefaultDuration, err = time.ParseDuration(file.DefaultDuration); err != nil {
+			return cfg, fmt.Errorf("invalid default_duration %q", file.DefaultDuration)
+		}
+	}
+	for _, host := range file.AllowedHosts {
+		cfg.AllowedHosts = append(cfg.AllowedHosts, strings.ToLower(strings.TrimSpace(host)))
+	}
+	for _, proxy := range file.TrustedProxies {
+		cfg.TrustedProxies = append(cfg.TrustedProxies, strings.TrimSpace(proxy))
+	}
+	for _, feed := range file.Feeds {
+		defaults, err := feed.parse()
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Feeds = append(cfg.Feeds, defaults)
+	}
+	cfg.AllowPrivateNetworks = file.AllowPrivateNetworks
+	cfg.AllowCookieHeader = file.AllowCookieHeader
+	if file.MaxRedirects != nil {
+		cfg.MaxRedirects = *file.MaxRedirects
+	}
+	if file.FetchRetries != nil {
+		cfg.FetchRetries = *file.FetchRetries
+	}
+	if file.MaxFeedBytes != 0 {
+		cfg.MaxFeedBytes = file.MaxFeedBytes
+	}
+	if file.MaxItems != 0 {
+		cfg.MaxItems = file.MaxItems
+	}
+	if file.LogFormat != "" {
+		cfg.LogFormat = file.LogFormat
+	}
+	if file.UserAgent != "" {
+		cfg.UserAgent = file.UserAgent
+	}
+	if file.RateLimit != 0 {
+		cfg.RateLimit = file.RateLimit
+	}
+	if file.MaxConcurrentFetches != 0 {
+		cfg.MaxConcurrentFetches = file.MaxConcurrentFetches
+	}
+	if file.FetchTimeout != "" {
+		if cfg.FetchTimeout, err = time.ParseDuration(file.FetchTimeout); err != nil {
+			return cfg, fmt.Errorf("invalid fetch_timeout %q", file.FetchTimeout)
+		}
+	}
+	if file.NegativeCacheTTL != "" {
+		if cfg.NegativeCacheTTL, err = time.ParseDuration(file.NegativeCacheTTL); err != nil {
+			return cfg, fmt.Errorf("invalid negative_cache_ttl %q", file.NegativeCacheTTL)
+		}
+	}
+
+	return cfg, cfg.validate()
+}
+
+// withEnv returns c with any settings given as environment variables
+// applied on top.
+func (c Config) withEnv() Config {
+	if port := os.Getenv("PORT"); port != "" {
+		c.Port = port
+	}
+	if format := os.Getenv("LOG_FORMAT"); format != "" {
+		c.LogFormat = format
+	}
+	if backend := os.Getenv("CACHE_BACKEND"); backend != "" {
+		c.CacheBackend = backend
+	}
+	if redisURL := os.Getenv("REDIS_URL"); redisURL != "" {
+		c.RedisURL = redisURL
+	}
+	c.CacheTTL = durationFromEnv("CACHE_TTL", c.CacheTTL)
+	c.CacheMaxEntries = intFromEnv("CACHE_MAX_ENTRIES", c.CacheMaxEntries)
+	c.DefaultDuration = durationFromEnv("DEFAULT_DURATION", c.DefaultDuration)
+	c.MaxRedirects = nonNegativeIntFromEnv("MAX_REDIRECTS", c.MaxRedirects)
+	c.FetchRetries = nonNegativeIntFromEnv("FETCH_RETRIES", c.FetchRetries)
+	c.FetchTimeout = durationFromEnv("FETCH_TIMEOUT", c.FetchTimeout)
+	c.MaxFeedBytes = intFromEnv("MAX_FEED_BYTES", c.MaxFeedBytes)
+	c.MaxItems = intFromEnv("MAX_ITEMS", c.MaxItems)
+	c.MaxConcurrentFetches = intFromEnv("MAX_CONCURRENT_FETCHES", c.MaxConcurrentFetches)
+	c.RateLimit = intFromEnv("RATE_LIMIT", c.RateLimit)
+	c.NegativeCacheTTL = durationFromEnv("NEGATIVE_CACHE_TTL", c.NegativeCacheTTL)
+	if hosts := os.Getenv("ALLOWED_HOSTS"); hosts != "" {
+		c.AllowedHosts = splitHosts(hosts)
+	}
+	if agent := os.Getenv("USER_AGENT"); agent != "" {
+		c.UserAgent = agent
+	}
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		c.TrustedProxies = splitHosts(proxies)
+	}
+	if raw := os.Getenv("ALLOW_PRIVATE_NETWORKS"); raw != "" {
+		allow, err := strconv.ParseBool(raw)
+		if err != nil {
+			log.Printf("Invalid ALLOW_PRIVATE_NETWORKS %q, using %v", raw, c.AllowPrivateNetworks)
+		} else {
+			c.AllowPrivateNetworks = allow
+		}
+	}
+	if raw := os.Getenv("ALLOW_COOKIE_HEADER"); raw != "" {
+		allow, err := strconv.ParseBool(raw)
+		if err != nil {
+			log.Printf("Invalid ALLOW_COOKIE_HEADER %q, using %v", raw, c.AllowCookieHeader)
+		} else {
+			c.AllowCookieHeader = allow
+		}
+	}
+	return c
+}
+
+func (c Config) validate() error {
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("invalid port %q", c.Port)
+	}
+	if c.CacheTTL <= 0 {
+		return fmt.Errorf("invalid cache_ttl %v: must be positive", c.CacheTTL)
+	}
+	if c.CacheMaxEntries <= 0 {
+		return fmt.Errorf("invalid cache_max_entries %d: must be positive", c.CacheMaxEntries)
+	}
+	switch c.CacheBackend {
+	case cacheBackendMemory:
+	case cacheBackendRedis:
+		if c.RedisURL == "" {
+			return fmt.Errorf("invalid cache_backend redis: redis_url is required")
+		}
+		if _, err := newRedisCache(c.RedisURL); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid cache_backend %q: use memory or redis", c.CacheBackend)
+	}
+	if c.DefaultDuration < 0 {
+		return fmt.Errorf("invalid default_duration %v: must not be negative", c.DefaultDuration)
+	}
+	if c.NegativeCacheTTL < 0 {
+		return fmt.Errorf("invalid negative_cache_ttl %v: must not be negative", c.NegativeCacheTTL)
+	}
+	if c.MaxFeedBytes <= 0 {
+		return fmt.Errorf("invalid max_feed_bytes %d: must be positive", c.MaxFeedBytes)
+	}
+	if c.MaxItems <= 0 {
+		return fmt.Errorf("invalid max_items %d: must be positive", c.MaxItems)
+	}
+	if c.LogFormat != logFormatText && c.LogFormat != logFormatJSON {
+		return fmt.Errorf("invalid log_format %q: use text or json", c.LogFormat)
+	}
+	if c.MaxConcurrentFetches <= 0 {
+		return fmt.Errorf("invalid max_concurrent_fetches %d: must be positive", c.MaxConcurrentFetches)
+	}
+	if c.RateLimit < 0 {
+		return fmt.Errorf("invalid rate_limit %d: must not be negative", c.RateLimit)
+	}
+	if c.FetchTimeout <= 0 {
+		return fmt.Errorf("invalid fetch_timeout %v: must be positive", c.FetchTimeout)
+	}
+	if c.FetchRetries < 0 {
+		return fmt.Errorf("invalid fetch_retries %d: must not be negative", c.FetchRetries)
+	}
+	if c.MaxRedirects < 0 {
+		return fmt.Errorf("invalid max_redirects %d: must not be negative", c.MaxRedirects)
+	}
+	for _, host := range c.AllowedHosts {
+		if host == "" {
+			return fmt.Errorf("invalid allowed_hosts: empty host")
+		}
+	}
+	if strings.ContainsAny(c.UserAgent, "\r\n") {
+		return fmt.Errorf("invalid user_agent: must be a single line")
+	}
+	if _, err := parseTrustedProxies(c.TrustedProxies); err != nil {
+		return err
+	}
+	for _, feed := range c.Feeds {
+		if err := feed.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.Port != defaultPort || cfg.CacheTTL != defaultCacheTTL || cfg.CacheMaxEntries != defaultCacheMaxEntries {
+		t.Errorf("Expected defaults, got %+v", cfg)
+	}
+}
+
+func TestLoadConfigYAML(t *testing.T) {
+	path := writeConfig(t, "rss2ical.yaml", `
+port: 9090
+cache_ttl: 10m
+cache_max_entries: 50
+default_duration: 30m
+allowed_hosts:
+  - Example.com
+  - feeds.bbci.co.uk
+`)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.Port != "9090" {
+		t.Errorf("Expected port 9090, got %s", cfg.Port)
+	}
+	if cfg.CacheTTL != 10*time.Minute {
+		t.Errorf("Expected cache TTL 10m, got %v", cfg.CacheTTL)
+	}
+	if cfg.CacheMaxEntries != 50 {
+		t.Errorf("Expected 50 cache entries, got %d", cfg.CacheMaxEntries)
+	}
+	if cfg.DefaultDuration != 30*time.Minute {
+		t.Errorf("Expected default duration 30m, got %v", cfg.DefaultDuration)
+	}
+	if len(cfg.AllowedHosts) != 2 || cfg.AllowedHosts[0] != "example.com" {
+		t.Errorf("Expected normalized allowed hosts, got %v", cfg.AllowedHosts)
+	}
+}
+
+func TestLoadConfigJSON(t *testing.T) {
+	path := writeConfig(t, "rss2ical.json", `{"port": 9091, "cache_ttl": "1h"}`)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.Port != "9091" || cfg.CacheTTL != time.Hour {
+		t.Errorf("Expected port 9091 and TTL 1h, got %+v", cfg)
+	}
+	if cfg.CacheMaxEntries != defaultCacheMaxEntries {
+		t.Errorf("Expected default cache entries, got %d", cfg.CacheMaxEntries)
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"bad-ttl.yaml":     "cache_ttl: soon",
+		"bad-port.yaml":    "port: 70000",
+		"bad-entries.yaml": "cache_max_entries: -1",
+		"bad-syntax.json":  "{",
+		"bad-logs.yaml":    "log_format: xml",
+		"bad-proxies.yaml": "trusted_proxies: [10.0.0.0/33]",
+		"bad-backend.yaml": "cache_backend: memcached",
+		"no-redis.yaml":    "cache_backend: redis",
+		"bad-redis.yaml":   "cache_backend: redis\nredis_url: http://localhost:6379",
+	} {
+		if _, err := LoadConfig(writeConfig(t, name, content)); err == nil {
+			t.Errorf("Expected error for %s", name)
+		}
+	}
+
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Expected error for missing config file")
+	}
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	path := writeConfig(t, "rss2ical.yaml", `
+port: 9090
+cache_ttl: 10m
+default_duration: 30m
+`)
+	t.Setenv("PORT", "")
+	t.Setenv("CACHE_TTL", "1m")
+	t.Setenv("DEFAULT_DURATION", "")
+	t.Setenv("MAX_REDIRECTS", "0")
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cfg = cfg.withEnv()
+
+	// The environment overrides the file, which overrides the defaults
+	if cfg.CacheTTL != time.Minute {
+		t.Errorf("Expected env cache TTL 1m, got %v", cfg.CacheTTL)
+	}
+	if cfg.Port != "9090" {
+		t.Errorf("Expected file port 9090, got %s", cfg.Port)
+	}
+	if cfg.CacheMaxEntries != defaultCacheMaxEntries {
+		t.Errorf("Expected default cache entries, got %d", cfg.CacheMaxEntries)
+	}
+	if cfg.MaxRedirects != 0 {
+		t.Errorf("Expected env max redirects 0 to disable redirects, got %d", cfg.MaxRedirects)
+	}
+
+	// Query parameters override the configured event duration
+	defer func(d time.Duration) { eventDuration = d }(eventDuration)
+	eventDuration = cfg.DefaultDuration
+
+	opts, err := parseCalendarOptions(url.Values{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.Duration != 30*time.Minute {
+		t.Errorf("Expected configured duration 30m, got %v", opts.Duration)
+	}
+
+	opts, err = parseCalendarOptions(url.Values{"duration": {"2h"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.Duration != 2*time.Hour {
+		t.Errorf("Expected query duration 2h, got %v", opts.Duration)
+	}
+}
+
+func TestConfigRedisBackend(t *testing.T) {
+	t.Setenv("CACHE_BACKEND", "redis")
+	t.Setenv("REDIS_URL", "redis://localhost:6379/0")
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.CacheBackend != cacheBackendMemory {
+		t.Errorf("Expected the memory backend by default, got %q", cfg.CacheBackend)
+	}
+	cfg = cfg.withEnv()
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.CacheBackend != cacheBackendRedis || cfg.RedisURL != "redis://localhost:6379/0" {
+		t.Errorf("Expected the redis backend from the environment, got %q %q", cfg.CacheBackend, cfg.RedisURL)
+	}
+}
+
+func TestConfigFetchRetriesDisabled(t *testing.T) {
+	t.Setenv("FETCH_RETRIES", "0")
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cfg = cfg.withEnv()
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.FetchRetries != 0 {
+		t.Errorf("Expected env fetch retries 0 to disable retries, got %d", cfg.FetchRetries)
+	}
+}
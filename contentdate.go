@@ -0,0 +1,124 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	monthPattern = `(jan(?:uary)?|feb(?:ruary)?|mar(?:ch)?|apr(?:il)?|may|june?|july?|aug(?:ust)?|sep(?:t(?:ember)?)?|oct(?:ober)?|nov(?:ember)?|dec(?:ember)?)\.?`
+	// timePattern optionally follows a date, e.g. ", 7:00 PM" or " at 19:00"
+	timePattern = `(?:,?\s+(?:at\s+)?(\d{1,2})(?::(\d{2}))?\s*([ap]\.?m\.?)?)?`
+)
+
+var (
+	// 2025-01-05, 2025-01-05 19:00, 2025-01-05T19:00:00Z
+	isoDatePattern = regexp.MustCompile(`\b\d{4}-\d{2}-\d{2}(?:[T ]\d{2}:\d{2}(?::\d{2})?(?:Z|[+-]\d{2}:\d{2})?)?`)
+	// January 5, 2025 7:00 PM
+	monthFirstPattern = regexp.MustCompile(`(?i)\b` + monthPattern + `\s+(\d{1,2})(?:st|nd|rd|th)?,?\s+(\d{4})\b` + timePattern)
+	// 5 January 2025 19:00
+	dayFirstPattern = regexp.MustCompile(`(?i)\b(\d{1,2})(?:st|nd|rd|th)?\s+` + monthPattern + `,?\s+(\d{4})\b` + timePattern)
+)
+
+var isoDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+var months = map[string]time.Month{
+	"jan": time.January, "feb": time.February, "mar": time.March,
+	"apr": time.April, "may": time.May, "jun": time.June,
+	"jul": time.July, "aug": time.August, "sep": time.September,
+	"oct": time.October, "nov": time.November, "dec": time.December,
+}
+
+// extractContentDate finds the first recognizable date in text, such as an
+// event date written into a post body. Dates without an offset are read in loc.
+func extractContentDate(text string, loc *time.Location) (time.Time, bool) {
+	var found time.Time
+	foundAt := -1
+
+	consider := func(at int, t time.Time) {
+		if foundAt == -1 || at < foundAt {
+			found, foundAt = t, at
+		}
+	}
+
+	for _, match := range isoDatePattern.FindAllStringIndex(text, -1) {
+		for _, layout := range isoDateLayouts {
+			if t, err := time.ParseInLocation(layout, text[match[0]:match[1]], loc); err == nil {
+				consider(match[0], t)
+				break
+			}
+		}
+	}
+
+	for _, match := range monthFirstPattern.FindAllStringSubmatchIndex(text, -1) {
+		groups := submatches(text, match)
+		if t, ok := namedDate(groups[1], groups[2], groups[3], groups[4], groups[5], groups[6], loc); ok {
+			consider(match[0], t)
+		}
+	}
+
+	for _, match := range dayFirstPattern.FindAllStringSubmatchIndex(text, -1) {
+		groups := submatches(text, match)
+		if t, ok := namedDate(groups[2], groups[1], groups[3], groups[4], groups[5], groups[6], loc); ok {
+			consider(match[0], t)
+		}
+	}
+
+	return found, foundAt != -1
+}
+
+func submatches(text string, match []int) []string {
+	groups := make([]string, len(match)/2)
+	for i := range groups {
+		if match[2*i] >= 0 {
+			groups[i] = text[match[2*i]:match[2*i+1]]
+		}
+	}
+	return groups
+}
+
+// namedDate builds a time from the pieces of a written-out date. A bare
+// hour without minutes or AM/PM is not treated as a time of day.
+func namedDate(month, day, year, hour, minute, meridiem string, loc *time.Location) (time.Time, bool) {
+	m, ok := months[strings.ToLower(month)[:3]]
+	if !ok {
+		return time.Time{}, false
+	}
+	d, _ := strconv.Atoi(day)
+	y, _ := strconv.Atoi(year)
+	if d < 1 || d > 31 {
+		return time.Time{}, false
+	}
+
+	h, mins := 0, 0
+	if hour != "" && (minute != "" || meridiem != "") {
+		h, _ = strconv.Atoi(hour)
+		mins, _ = strconv.Atoi(minute)
+
+		meridiem = strings.ToLower(meridiem)
+		if strings.HasPrefix(meridiem, "p") && h < 12 {
+			h += 12
+		} else if strings.HasPrefix(meridiem, "a") && h == 12 {
+			h = 0
+		}
+		if h > 23 || mins > 59 {
+			return time.Time{}, false
+		}
+	}
+
+	t := time.Date(y, m, d, h, mins, 0, 0, loc)
+	if t.Day() != d {
+		// Normalized past the end of the month, e.g. February 30
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExtractContentDate(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected time.Time
+	}{
+		{"Join us January 5, 2025 7:00 PM at the library", time.Date(2025, time.January, 5, 19, 0, 0, 0, time.UTC)},
+		{"Meetup on Jan 5 2025 at 7pm", time.Date(2025, time.January, 5, 19, 0, 0, 0, time.UTC)},
+		{"Doors open Sept. 12th, 2025, 9:30 a.m.", time.Date(2025, time.September, 12, 9, 30, 0, 0, time.UTC)},
+		{"Conference: 5 March 2025 14:00", time.Date(2025, time.March, 5, 14, 0, 0, 0, time.UTC)},
+		{"Starts 2025-01-05T19:00:00Z sharp", time.Date(2025, time.January, 5, 19, 0, 0, 0, time.UTC)},
+		{"Date: 2025-01-05", time.Date(2025, time.January, 5, 0, 0, 0, 0, time.UTC)},
+		{"Happening December 31, 2025", time.Date(2025, time.December, 31, 0, 0, 0, 0, time.UTC)},
+		{"Lunch at 12:00 AM? No, December 1, 2025 12:00 PM", time.Date(2025, time.December, 1, 12, 0, 0, 0, time.UTC)},
+		{"Either 2025-02-01 or February 2, 2025", time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		result, ok := extractContentDate(test.text, time.UTC)
+		if !ok {
+			t.Errorf("Expected a date in %q", test.text)
+			continue
+		}
+		if !result.Equal(test.expected) {
+			t.Errorf("extractContentDate(%q) = %v, expected %v", test.text, result, test.expected)
+		}
+	}
+}
+
+func TestExtractContentDateNotFound(t *testing.T) {
+	for _, text := range []string{"", "No dates here", "February 30, 2025", "Room 5 May"} {
+		if result, ok := extractContentDate(text, time.UTC); ok {
+			t.Errorf("Expected no date in %q, got %v", text, result)
+		}
+	}
+}
+
+func TestExtractContentDateLocation(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("Failed to load location: %v", err)
+	}
+
+	result, ok := extractContentDate("Kickoff July 4, 2025 8:00 PM", loc)
+	if !ok {
+		t.Fatal("Expected a date")
+	}
+	if expected := time.Date(2025, time.July, 4, 20, 0, 0, 0, loc); !result.Equal(expected) {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+}
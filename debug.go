@@ -0,0 +1,110 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"time"
+)
+
+// debugEnabled turns on /debug. It exposes raw feed contents, so it is off
+// unless DEBUG_ENABLED is set.
+var debugEnabled bool
+
+// debugRow is one parsed item as shown by /debug.
+type debugRow struct {
+	Title   string
+	PubDate string
+	Parsed  string
+	Error   string
+	GUID    string
+	Link    string
+}
+
+var debugTemplate = template.Must(template.New("debug").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>RSS2ICal debug - {{.Feed}}</title>
+    <style>
+        body { font-family: sans-serif; margin: 1rem; }
+        table { border-collapse: collapse; }
+        th, td { border: 1px solid #ccc; padding: 0.25rem 0.5rem; text-align: left; vertical-align: top; }
+        .error { color: #b00; }
+    </style>
+</head>
+<body>
+    <h1>{{.Title}}</h1>
+    <p>{{.Feed}}: {{len .Rows}} items</p>
+    <table>
+        <tr><th>Title</th><th>pubDate</th><th>Parsed time</th><th>GUID</th><th>Link</th></tr>
+        {{- range .Rows}}
+        <tr><td>{{.Title}}</td><td>{{.PubDate}}</td><td>{{if .Error}}<span class="error">{{.Error}}</span>{{else}}{{.Parsed}}{{end}}</td><td>{{.GUID}}</td><td>{{.Link}}</td></tr>
+        {{- end}}
+    </table>
+</body>
+</html>
+`))
+
+// debugHandler renders a feed's items as parsed, before conversion, to help
+// tell a parsing problem from a conversion one.
+func debugHandler(w http.ResponseWriter, r *http.Request) {
+	if !debugEnabled {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query, err := calendarQuery(r.URL.RawQuery)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	urls := feedURLs(query["url"])
+	if len(urls) != 1 {
+		http.Error(w, "One RSS URL required: use ?url=... parameter", http.StatusBadRequest)
+		return
+	}
+	feedURL, err := normalizeFeedURL(urls[0])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !hostAllowed(feedURL) {
+		http.Error(w, "Feed host not allowed", http.StatusForbidden)
+		return
+	}
+	opts, err := parseCalendarOptions(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rss, err := fetchRSS(r.Context(), feedURL)
+	if err != nil {
+		loggerFrom(r.Context()).Warn("debug fetch failed", "url", redactURL(feedURL), "error", err)
+		http.Error(w, feedErrorReason(err), http.StatusBadGateway)
+		return
+	}
+
+	rows := make([]debugRow, 0, len(rss.Channel.Items))
+	for _, item := range rss.Channel.Items {
+		row := debugRow{Title: item.Title, PubDate: item.published(), GUID: item.GUID, Link: item.Link}
+		if parsed, err := parseTimeIn(item.published(), opts.location()); err != nil {
+			row.Error = err.Error()
+		} else {
+			row.Parsed = parsed.Format(time.RFC3339)
+		}
+		rows = append(rows, row)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	debugTemplate.Execute(w, struct {
+		Feed  string
+		Title string
+		Rows  []debugRow
+	}{redactURL(feedURL), rss.Channel.Title, rows})
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebugHandler(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(`<?xml version="1.0"?>
+<rss version="2.0"><channel><title>Debug Feed</title>
+<item><title>Parsed &amp; Dated</title><pubDate>Mon, 01 Jan 2024 12:00:00 GMT</pubDate><guid>item-1</guid></item>
+<item><title>Oddly Dated</title><pubDate>sometime soon</pubDate></item>
+</channel></rss>`))
+	}))
+	defer mockServer.Close()
+
+	defer func(enabled bool) { debugEnabled = enabled }(debugEnabled)
+	debugEnabled = true
+
+	req := httptest.NewRequest("GET", "/debug?url="+mockServer.URL, nil)
+	w := httptest.NewRecorder()
+	debugHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d: %s", w.Code, w.Body.String())
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		"<td>Parsed &amp; Dated</td>",
+		"<td>Oddly Dated</td>",
+		"2024-01-01T12:00:00Z",
+		"<td>item-1</td>",
+		`unrecognized date &#34;sometime soon&#34;`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected debug table to contain %q, got:\n%s", want, body)
+		}
+	}
+}
+
+func TestDebugHandlerRedactsFetchError(t *testing.T) {
+	mockServer := httptest.NewServer(http.NotFoundHandler())
+	mockServer.Close()
+
+	defer func(enabled bool) { debugEnabled = enabled }(debugEnabled)
+	debugEnabled = true
+	defer func(n int) { fetchRetries = n }(fetchRetries)
+	fetchRetries = 0
+
+	feedURL := strings.Replace(mockServer.URL, "http://", "http://user:secret@", 1)
+	req := httptest.NewRequest("GET", "/debug?url="+feedURL, nil)
+	w := httptest.NewRecorder()
+	debugHandler(w, req)
+
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("Expected status code 502, got %d: %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "secret") {
+		t.Errorf("Expected the feed password to be redacted, got %q", w.Body.String())
+	}
+}
+
+func TestDebugHandlerDisabled(t *testing.T) {
+	defer func(enabled bool) { debugEnabled = enabled }(debugEnabled)
+	debugEnabled = false
+
+	req := httptest.NewRequest("GET", "/debug?url=https://test.com/rss.xml", nil)
+	w := httptest.NewRecorder()
+	debugHandler(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code 404, got %d", w.Code)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// feedLinkTypes are the <link rel="alternate"> types that announce a feed.
+var feedLinkTypes = map[string]bool{
+	"application/rss+xml":  true,
+	"application/atom+xml": true,
+}
+
+type feedDiscoveryKey struct{}
+
+// withFeedDiscovery returns a context whose feed fetches follow an HTML
+// page's feed link when the URL turns out to be a web page.
+func withFeedDiscovery(ctx context.Context, enabled bool) context.Context {
+	return context.WithValue(ctx, feedDiscoveryKey{}, enabled)
+}
+
+// feedDiscovery reports whether fetches under ctx discover feeds.
+func feedDiscovery(ctx context.Context) bool {
+	enabled, _ := ctx.Value(feedDiscoveryKey{}).(bool)
+	return enabled
+}
+
+// isHTMLPage reports whether a fetched body is a web page rather than a
+// feed. The body is sniffed since feeds are often served as text/html.
+func isHTMLPage(body []byte) bool {
+	return strings.HasPrefix(http.DetectContentType(body), "text/html")
+}
+
+// discoverFeedURL returns the first RSS or Atom feed an HTML page links to
+// with <link rel="alternate">, resolved against the page's URL.
+func discoverFeedURL(page []byte, base *url.URL) (string, bool) {
+	tokenizer := html.NewTokenizer(bytes.NewReader(page))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return "", false
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			if string(name) != "link" || !hasAttr {
+				continue
+			}
+			var rel, linkType, href string
+			for more := true; more; {
+				var key, value []byte
+				key, value, more = tokenizer.TagAttr()
+				switch string(key) {
+				case "rel":
+					rel = strings.ToLower(string(value))
+				case "type":
+					linkType = strings.ToLower(strings.TrimSpace(string(value)))
+				case "href":
+					href = strings.TrimSpace(string(value))
+				}
+			}
+			if href == "" || !feedLinkTypes[linkType] || !hasToken(rel, "alternate") {
+				continue
+			}
+			ref, err := url.Parse(href)
+			if err != nil {
+				continue
+			}
+			return base.ResolveReference(ref).String(), true
+		}
+	}
+}
+
+// hasToken reports whether a space-separated list such as a rel attribute
+// contains token.
+func hasToken(list, token string) bool {
+	for _, field := range strings.Fields(list) {
+		if field == token {
+			return true
+		}
+	}
+	return false
+}
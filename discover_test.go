@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestDiscoverFeedURL(t *testing.T) {
+	base, _ := url.Parse("https://example.com/blog/")
+	tests := []struct {
+		page string
+		want string
+	}{
+		{`<html><head><link rel="alternate" type="application/rss+xml" href="/feed.xml"></head></html>`, "https://example.com/feed.xml"},
+		{`<html><head><link rel="stylesheet" href="/style.css"><link rel="Alternate" type="application/atom+xml" href="atom.xml"/></head></html>`, "https://example.com/blog/atom.xml"},
+		{`<html><head><link rel="alternate" type="text/html" hreflang="fr" href="/fr/"><link rel="alternate" type="application/rss+xml" href="https://feeds.example.net/main"></head></html>`, "https://feeds.example.net/main"},
+		{`<html><head><link rel="alternate" type="application/rss+xml" href="/first.xml"><link rel="alternate" type="application/rss+xml" href="/second.xml"></head></html>`, "https://example.com/first.xml"},
+	}
+	for _, tt := range tests {
+		got, ok := discoverFeedURL([]byte(tt.page), base)
+		if !ok || got != tt.want {
+			t.Errorf("discoverFeedURL(%s) = %q, %v; want %q", tt.page, got, ok, tt.want)
+		}
+	}
+
+	if got, ok := discoverFeedURL([]byte(`<html><head><link rel="alternate" type="application/rss+xml"></head></html>`), base); ok {
+		t.Errorf("Expected no feed for a link without href, got %q", got)
+	}
+	if got, ok := discoverFeedURL([]byte(`<html><body>No feeds here</body></html>`), base); ok {
+		t.Errorf("Expected no feed, got %q", got)
+	}
+}
+
+func TestIsHTMLPage(t *testing.T) {
+	if !isHTMLPage([]byte("<!DOCTYPE html><html><head></head></html>")) {
+		t.Error("Expected an HTML page to be detected")
+	}
+	if isHTMLPage([]byte(mockRSSFeed)) {
+		t.Error("Expected an RSS feed not to be taken for a page")
+	}
+}
+
+func TestCalendarHandlerDiscoversFeed(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		// Served as text/html, like many feeds are
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<!DOCTYPE html><html><head><title>Blog</title>
+<link rel="alternate" type="application/rss+xml" title="Blog" href="/feed.xml">
+</head><body><h1>Blog</h1></body></html>`))
+	})
+	mux.HandleFunc("/feed.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(mockRSSFeed))
+	})
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	for _, path := range []string{"/", "/feed.xml"} {
+		cache = &Cache{}
+		failures = &NegativeCache{}
+
+		req := httptest.NewRequest("GET", "/calendar?discover=true&url="+url.QueryEscape(mockServer.URL+path), nil)
+		w := httptest.NewRecorder()
+		calendarHandler(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", path, w.Code, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), "SUMMARY:Test Item 1") {
+			t.Errorf("%s: expected the feed's events, got:\n%s", path, w.Body.String())
+		}
+	}
+
+	// Without discover the page isn't a feed
+	cache = &Cache{}
+	failures = &NegativeCache{}
+	req := httptest.NewRequest("GET", "/calendar?url="+url.QueryEscape(mockServer.URL+"/"), nil)
+	w := httptest.NewRecorder()
+	calendarHandler(w, req)
+	if w.Code == http.StatusOK {
+		t.Error("Expected the HTML page to fail without discover=true")
+	}
+}
+
+func TestCalendarHandlerDiscoverNoFeedLink(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<!DOCTYPE html><html><head><title>No feed</title></head></html>`))
+	}))
+	defer mockServer.Close()
+
+	cache = &Cache{}
+	failures = &NegativeCache{}
+	req := httptest.NewRequest("GET", "/calendar?discover=true&url="+url.QueryEscape(mockServer.URL), nil)
+	w := httptest.NewRecorder()
+	calendarHandler(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", w.Code)
+	}
+}
+
+func TestFetchFeedOnceChecksDiscoveredURL(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<!DOCTYPE html><html><head>
+<link rel="alternate" type="application/rss+xml" href="file:///etc/passwd">
+</head></html>`))
+	}))
+	defer mockServer.Close()
+
+	ctx := withFeedDiscovery(context.Background(), true)
+	_, err := fetchFeedOnce(ctx, mockServer.URL, Validators{})
+	if err == nil || !strings.Contains(err.Error(), "unsupported feed URL scheme") {
+		t.Errorf("Expected a discovered file: URL to be refused as a feed URL, got %v", err)
+	}
+}
+
+func TestCalendarHandlerDiscoverPaginate(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/blog/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<!DOCTYPE html><html><head>
+<link rel="alternate" type="application/atom+xml" href="/feeds/feed.xml">
+</head></html>`))
+	})
+	mux.HandleFunc("/feeds/feed.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<?xml version="1.0"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><title>Paged</title>
+<link rel="next" href="page2.xml"/>
+<entry><id>item-1</id><title>Page One Item</title><published>2024-01-02T12:00:00Z</published></entry>
+</feed>`))
+	})
+	mux.HandleFunc("/feeds/page2.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<?xml version="1.0"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><title>Paged</title>
+<entry><id>item-2</id><title>Page Two Item</title><published>2024-01-01T12:00:00Z</published></entry>
+</feed>`))
+	})
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+
+	cache = &Cache{}
+	failures = &NegativeCache{}
+	req := httptest.NewRequest("GET", "/calendar?discover=true&paginate=true&url="+url.QueryEscape(mockServer.URL+"/blog/"), nil)
+	w := httptest.NewRecorder()
+	calendarHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	// The next link is relative to the discovered feed, not the page
+	if !strings.Contains(w.Body.String(), "SUMMARY:Page Two Item") {
+		t.Errorf("Expected the second page's events, got:\n%s", w.Body.String())
+	}
+}
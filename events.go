@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	formatICS  = "ics"
+	formatJSON = "json"
+)
+
+// Event is the JSON form of a calendar event.
+type Event struct {
+	UID         string    `json:"uid"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	URL         string    `json:"url"`
+	Author      string    `json:"author,omitempty"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+}
+
+// rssToJSON converts a feed into a JSON array of events, built from the
+// same items as rssToICal.
+func rssToJSON(rss *RSS, opts CalendarOptions) (string, error) {
+	return marshalEvents(jsonEvents(rss, opts))
+}
+
+// jsonEvents returns the JSON events of a feed's items.
+func jsonEvents(rss *RSS, opts CalendarOptions) []Event {
+	events := []Event{}
+	for _, item := range calendarItems(rss, opts) {
+		events = append(events, Event{
+			UID:         item.eventUID,
+			Title:       item.Title,
+			Description: item.eventDescription,
+			URL:         item.Link,
+			Author:      item.contact(),
+			Start:       item.startTime,
+			End:         item.endTime,
+		})
+	}
+	return events
+}
+
+func marshalEvents(events []Event) (string, error) {
+	data, err := json.Marshal(events)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// conversion is a feed converted in the format opts asks for but not yet
+// serialized, so it can be streamed to the client as it is written.
+type conversion struct {
+	// cal is the calendar, or nil for JSON, which is small enough to keep
+	// marshaled
+	cal      *ics.Calendar
+	json     string
+	unfolded bool
+	// entry holds the versions and counts for the cache, without data
+	entry CacheEntry
+}
+
+// prepareCalendar converts a feed in the format opts asks for. Calendars carry
+// event versions on from previous and count the items skipped because their
+// event couldn't be built; JSON has neither.
+func prepareCalendar(ctx context.Context, rss *RSS, opts CalendarOptions, previous eventVersions) (conv *conversion, err error) {
+	_, span := tracer.Start(ctx, "convert feed", trace.WithAttributes(
+		attribute.String("calendar.format", opts.Format),
+		attribute.Int("feed.items", len(rss.Channel.Items))))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "conversion failed")
+		} else {
+			span.SetAttributes(attribute.Int("calendar.skipped_items", conv.entry.skipped))
+		}
+		span.End()
+	}()
+
+	if opts.Format == formatJSON {
+		events := jsonEvents(rss, opts)
+		data, err := marshalEvents(events)
+		if err != nil {
+			return nil, err
+		}
+		return &conversion{json: data, entry: CacheEntry{events: len(events)}}, nil
+	}
+
+	cal, versions, skipped, err := buildVersionedCalendar(rss, opts, previous)
+	if err != nil {
+		return nil, err
+	}
+	return &conversion{
+		cal:      cal,
+		unfolded: opts.Unfolded,
+		entry:    CacheEntry{versions: versions, events: len(cal.Events()), skipped: skipped},
+	}, nil
+}
+
+// writeTo serializes the conversion to w.
+func (c *conversion) writeTo(w io.Writer) error {
+	if c.cal == nil {
+		_, err := io.WriteString(w, c.json)
+		return err
+	}
+	if !c.unfolded {
+		return serializeCalendar(c.cal, w)
+	}
+	unfolded := &unfoldWriter{w: w}
+	if err := serializeCalendar(c.cal, unfolded); err != nil {
+		return err
+	}
+	return unfolded.Flush()
+}
+
+// renderCalendar converts a feed as prepareCalendar does, returning an entry
+// holding the serialized data.
+func renderCalendar(ctx context.Context, rss *RSS, opts CalendarOptions, previous eventVersions) (CacheEntry, error) {
+	conv, err := prepareCalendar(ctx, rss, opts, previous)
+	if err != nil {
+		return CacheEntry{}, err
+	}
+	var b strings.Builder
+	if err := conv.writeTo(&b); err != nil {
+		return CacheEntry{}, err
+	}
+	entry := conv.entry
+	entry.data = b.String()
+	return entry, nil
+}
+
+// acceptsJSON reports whether the request's Accept header asks for JSON.
+func acceptsJSON(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted)); err == nil && mediaType == "application/json" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalendarHandlerJSON(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(mockRSSFeed))
+	}))
+	defer mockServer.Close()
+
+	for name, configure := range map[string]func(*http.Request){
+		"format parameter": func(r *http.Request) {
+			q := r.URL.Query()
+			q.Set("format", "json")
+			r.URL.RawQuery = q.Encode()
+		},
+		"accept header": func(r *http.Request) {
+			r.Header.Set("Accept", "application/json")
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// Clear cache for clean test
+			cache = &Cache{}
+
+			req := httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil)
+			configure(req)
+			w := httptest.NewRecorder()
+			calendarHandler(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status code 200, got %d", w.Code)
+			}
+			if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "application/json") {
+				t.Errorf("Expected JSON content type, got '%s'", contentType)
+			}
+
+			var events []Event
+			if err := json.Unmarshal(w.Body.Bytes(), &events); err != nil {
+				t.Fatalf("Failed to decode events: %v", err)
+			}
+			if len(events) != 2 {
+				t.Fatalf("Expected 2 events, got %d", len(events))
+			}
+
+			first := events[0]
+			if first.UID != "test-guid-1" || first.Title != "Test Item 1" || first.URL != "https://example.com/1" {
+				t.Errorf("Unexpected first event: %+v", first)
+			}
+			if !first.Start.Equal(time.Date(2025, 7, 27, 12, 0, 0, 0, time.UTC)) || first.End.Sub(first.Start) != time.Hour {
+				t.Errorf("Unexpected event times: %v to %v", first.Start, first.End)
+			}
+		})
+	}
+}
+
+func TestCalendarHandlerJSONCachedSeparately(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(mockRSSFeed))
+	}))
+	defer mockServer.Close()
+
+	// Clear cache for clean test
+	cache = &Cache{}
+
+	ics := httptest.NewRecorder()
+	calendarHandler(ics, httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil))
+
+	req := httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	calendarHandler(w, req)
+
+	if strings.Contains(w.Body.String(), "BEGIN:VCALENDAR") {
+		t.Error("Expected JSON response not to be served from the iCalendar cache entry")
+	}
+	if !strings.Contains(ics.Body.String(), "BEGIN:VCALENDAR") {
+		t.Error("Expected iCalendar by default")
+	}
+}
+
+func TestAcceptsJSON(t *testing.T) {
+	for accept, expected := range map[string]bool{
+		"application/json":                  true,
+		"text/html, application/json;q=0.9": true,
+		"text/calendar":                     false,
+		"":                                  false,
+	} {
+		req := httptest.NewRequest("GET", "/calendar", nil)
+		req.Header.Set("Accept", accept)
+		if got := acceptsJSON(req); got != expected {
+			t.Errorf("acceptsJSON(%q) = %v, expected %v", accept, got, expected)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// feedErrorUID is the UID of the event reporting a broken feed, so each
+// refresh replaces it rather than adding another.
+const feedErrorUID = "feed-error@rss2ical"
+
+// writeFeedError answers a feed that failed to fetch or parse with a 200
+// calendar holding a single "Feed error" event dated now, for errorsInline
+// mode: a subscribed client then shows the problem instead of silently
+// failing to refresh. The response isn't cached.
+func writeFeedError(w http.ResponseWriter, r *http.Request, opts CalendarOptions, err error) {
+	data, renderErr := renderFeedError(opts, feedErrorReason(err), time.Now())
+	if renderErr != nil {
+		loggerFrom(r.Context()).Error("feed error event conversion failed", "error", renderErr)
+		http.Error(w, "Failed to fetch RSS feed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("X-Event-Count", "1")
+	writeCalendar(w, r, opts.contentType(), data)
+}
+
+// renderFeedError renders the error event in the format opts asks for.
+func renderFeedError(opts CalendarOptions, reason string, now time.Time) (string, error) {
+	if opts.Location != nil {
+		now = now.In(opts.Location)
+	}
+	item := scheduledItem{
+		Item:             Item{Title: "Feed error: " + reason},
+		pubTime:          now,
+		modTime:          now,
+		startTime:        now,
+		endTime:          now.Add(opts.Duration),
+		dated:            true,
+		eventUID:         opts.UIDPrefix + feedErrorUID,
+		eventDescription: "The feed behind this calendar could not be refreshed: " + reason + ". Events will return once it can be fetched again.",
+	}
+
+	if opts.Format == formatJSON {
+		return marshalEvents([]Event{{
+			UID:         item.eventUID,
+			Title:       item.Title,
+			Description: item.eventDescription,
+			Start:       item.startTime,
+			End:         item.endTime,
+		}})
+	}
+
+	// The event reports the failure; feed-wide styling doesn't apply to it
+	opts.AllDay = false
+	opts.Alarm = 0
+	opts.Busy = false
+	opts.Status = ""
+	cal := newCalendar(Channel{}, opts)
+	if err := addEvent(cal, item, eventVersion{stamp: now}, opts); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := serializeCalendar(cal, &b); err != nil {
+		return "", err
+	}
+	if opts.Unfolded {
+		return unfoldLines(b.String()), nil
+	}
+	return b.String(), nil
+}
+
+// feedErrorReason describes a fetch error for the error event. A feed URL
+// quoted by the HTTP client has its password redacted, since the event is
+// shown to whoever the calendar is shared with.
+func feedErrorReason(err error) string {
+	reason := err.Error()
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		reason = strings.ReplaceAll(reason, urlErr.URL, redactURL(urlErr.URL))
+	}
+	return reason
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FeedDefaults are conversion settings for feeds whose URL matches Match,
+// used when a request doesn't set them itself.
+type FeedDefaults struct {
+	// Match is a feed URL pattern in which * matches any run of
+	// characters, e.g. https://podcasts.example.com/*.
+	Match string
+	// Duration, Alarm and AllDay stand in for the duration, alarm and
+	// allday parameters; nil leaves the usual default.
+	Duration *time.Duration
+	Alarm    *time.Duration
+	AllDay   *bool
+}
+
+// feedDefaultsFile is the on-disk form of FeedDefaults.
+type feedDefaultsFile struct {
+	Match    string `json:"match" yaml:"match"`
+	Duration string `json:"duration" yaml:"duration"`
+	Alarm    string `json:"alarm" yaml:"alarm"`
+	AllDay   *bool  `json:"allday" yaml:"allday"`
+}
+
+// feedDefaults are checked in order; the first rule matching a feed wins.
+var feedDefaults []FeedDefaults
+
+func (f feedDefaultsFile) parse() (FeedDefaults, error) {
+	defaults := FeedDefaults{Match: strings.TrimSpace(f.Match), AllDay: f.AllDay}
+	if f.Duration != "" {
+		duration, err := time.ParseDuration(f.Duration)
+		if err != nil {
+			return defaults, fmt.Errorf("invalid feeds duration %q", f.Duration)
+		}
+		defaults.Duration = &duration
+	}
+	if f.Alarm != "" {
+		alarm, err := time.ParseDuration(f.Alarm)
+		if err != nil {
+			return defaults, fmt.Errorf("invalid feeds alarm %q", f.Alarm)
+		}
+		defaults.Alarm = &alarm
+	}
+	return defaults, nil
+}
+
+func (d FeedDefaults) validate() error {
+	if d.Match == "" {
+		return fmt.Errorf("invalid feeds entry: match is required")
+	}
+	if d.Duration != nil && *d.Duration < 0 {
+		return fmt.Errorf("invalid feeds duration %v for %q: must not be negative", *d.Duration, d.Match)
+	}
+	if d.Alarm != nil && *d.Alarm <= 0 {
+		return fmt.Errorf("invalid feeds alarm %v for %q: must be positive", *d.Alarm, d.Match)
+	}
+	return nil
+}
+
+// applyFeedDefaults fills in the query parameters the first feedDefaults
+// rule matching one of urls sets, unless the request gave them.
+func applyFeedDefaults(query url.Values, urls []string) {
+	for _, feedURL := range urls {
+		for _, defaults := range feedDefaults {
+			if !matchURLPattern(defaults.Match, feedURL) {
+				continue
+			}
+			if defaults.Duration != nil && query.Get("duration") == "" {
+				query.Set("duration", defaults.Duration.String())
+			}
+			if defaults.Alarm != nil && query.Get("alarm") == "" {
+				query.Set("alarm", defaults.Alarm.String())
+			}
+			if defaults.AllDay != nil && query.Get("allday") == "" {
+				query.Set("allday", strconv.FormatBool(*defaults.AllDay))
+			}
+			return
+		}
+	}
+}
+
+// matchURLPattern reports whether feedURL matches pattern, where * matches
+// any run of characters and everything else matches case-insensitively.
+func matchURLPattern(pattern, feedURL string) bool {
+	parts := strings.Split(strings.ToLower(pattern), "*")
+	rest := strings.ToLower(feedURL)
+	if len(parts) == 1 {
+		return rest == parts[0]
+	}
+	if !strings.HasPrefix(rest, parts[0]) {
+		return false
+	}
+	rest = rest[len(parts[0]):]
+	last := len(parts) - 1
+	for _, part := range parts[1:last] {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+	return strings.HasSuffix(rest, parts[last])
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCalendarHandlerFeedDefaults(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(`<?xml version="1.0"?>
+<rss version="2.0"><channel><title>Feed</title>
+<item><title>Item</title><guid>item-1</guid><pubDate>Sun, 27 Jul 2025 12:00:00 GMT</pubDate></item>
+</channel></rss>`))
+	}))
+	defer mockServer.Close()
+
+	cfg, err := LoadConfig(writeConfig(t, "feeds.yaml", `
+feeds:
+  - match: "`+mockServer.URL+`/podcast/*"
+    duration: 45m
+    alarm: 10m
+  - match: "*/blog*"
+    duration: 5m
+`))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	defer func(d []FeedDefaults) { feedDefaults = d }(feedDefaults)
+	feedDefaults = cfg.Feeds
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/podcast/episodes.xml", []string{"DTEND:20250727T124500Z", "TRIGGER:-PT10M"}},
+		{"/blog/feed", []string{"DTEND:20250727T120500Z"}},
+		{"/blog/feed&duration=2h", []string{"DTEND:20250727T140000Z"}},
+		{"/news", []string{"DTEND:20250727T130000Z"}},
+	}
+	for _, tt := range tests {
+		cache = &Cache{}
+		failures = &NegativeCache{}
+		req := httptest.NewRequest("GET", "/calendar?url="+mockServer.URL+tt.path, nil)
+		w := httptest.NewRecorder()
+		calendarHandler(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status code 200, got %d", tt.path, w.Code)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(w.Body.String(), want) {
+				t.Errorf("%s: expected %q in output, got: %s", tt.path, want, w.Body.String())
+			}
+		}
+	}
+}
+
+func TestMatchURLPattern(t *testing.T) {
+	tests := []struct {
+		pattern, url string
+		want         bool
+	}{
+		{"https://example.com/feed", "https://example.com/feed", true},
+		{"https://example.com/feed", "https://example.com/feed2", false},
+		{"https://example.com/*", "https://EXAMPLE.com/a/b?c=d", true},
+		{"*youtube.com*", "https://www.youtube.com/feeds/videos.xml", true},
+		{"*/podcast/*.xml", "https://example.com/podcast/show.xml", true},
+		{"*/podcast/*.xml", "https://example.com/podcast/show.rss", false},
+	}
+	for _, tt := range tests {
+		if got := matchURLPattern(tt.pattern, tt.url); got != tt.want {
+			t.Errorf("matchURLPattern(%q, %q) = %v, want %v", tt.pattern, tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigInvalidFeeds(t *testing.T) {
+	for name, content := range map[string]string{
+		"no-match.yaml":  "feeds: [{duration: 1h}]",
+		"bad-dur.yaml":   "feeds: [{match: '*', duration: soon}]",
+		"bad-alarm.yaml": "feeds: [{match: '*', alarm: 0s}]",
+	} {
+		if _, err := LoadConfig(writeConfig(t, name, content)); err == nil {
+			t.Errorf("Expected error for %s", name)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultMaxConcurrentFetches = 20
+	defaultFetchSlotWait        = 2 * time.Second
+)
+
+// errFetchBusy is returned when no fetch slot frees up in time.
+var errFetchBusy = errors.New("too many concurrent feed fetches")
+
+// fetchSlots caps how many upstream fetches run at once; each running fetch
+// holds one slot.
+var fetchSlots = make(chan struct{}, defaultMaxConcurrentFetches)
+
+// fetchSlotWait is how long a fetch waits for a free slot.
+var fetchSlotWait = defaultFetchSlotWait
+
+// acquireFetchSlot blocks until a fetch slot is free, the wait runs out or
+// ctx is done. The returned func releases the slot.
+func acquireFetchSlot(ctx context.Context) (func(), error) {
+	slots := fetchSlots
+	timer := time.NewTimer(fetchSlotWait)
+	defer timer.Stop()
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-timer.C:
+		return nil, errFetchBusy
+	case <-ctx.Done():
+		return nil, fmt.Errorf("failed to fetch RSS: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFetchConcurrencyLimit(t *testing.T) {
+	var mu sync.Mutex
+	var inFlight, peak int
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > peak {
+			peak = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(mockRSSFeed))
+	}))
+	defer mockServer.Close()
+
+	defer func(slots chan struct{}) { fetchSlots = slots }(fetchSlots)
+	fetchSlots = make(chan struct{}, 2)
+	defer func(wait time.Duration) { fetchSlotWait = wait }(fetchSlotWait)
+	fetchSlotWait = 5 * time.Second
+
+	// Clear cache for clean test
+	cache = &Cache{}
+	failures = &NegativeCache{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Distinct feed URLs so every request fetches upstream
+			req := httptest.NewRequest("GET", fmt.Sprintf("/calendar?url=%s/feed%d", mockServer.URL, i), nil)
+			w := httptest.NewRecorder()
+			calendarHandler(w, req)
+			if w.Code != http.StatusOK {
+				t.Errorf("Expected status code 200, got %d", w.Code)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if peak > 2 {
+		t.Errorf("Expected at most 2 concurrent fetches, got %d", peak)
+	}
+}
+
+func TestCalendarHandlerFetchBusy(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected no upstream request while all fetch slots are taken")
+	}))
+	defer mockServer.Close()
+
+	defer func(slots chan struct{}) { fetchSlots = slots }(fetchSlots)
+	fetchSlots = make(chan struct{}, 1)
+	fetchSlots <- struct{}{}
+	defer func(wait time.Duration) { fetchSlotWait = wait }(fetchSlotWait)
+	fetchSlotWait = 10 * time.Millisecond
+
+	// Clear cache for clean test
+	cache = &Cache{}
+	failures = &NegativeCache{}
+
+	req := httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil)
+	w := httptest.NewRecorder()
+	calendarHandler(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status code 503, got %d", w.Code)
+	}
+	if failures.Failed(mockServer.URL) {
+		t.Error("Expected a busy fetch not to be remembered as a failure")
+	}
+
+	// Cached calendars are served without a slot
+	cache.Store(mockServer.URL, CacheEntry{data: "BEGIN:VCALENDAR"})
+	w = httptest.NewRecorder()
+	calendarHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected cached calendar with status code 200, got %d", w.Code)
+	}
+}
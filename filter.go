@@ -0,0 +1,84 @@
+package main
+
+import "strings"
+
+// filterItems keeps items matching any of opts.Contains (when given) and none
+// of opts.Excludes, and matching opts.Match when set. Terms match
+// case-insensitively against the title and description.
+func filterItems(items []Item, opts CalendarOptions) []Item {
+	if len(opts.Contains) == 0 && len(opts.Excludes) == 0 && opts.Match == nil {
+		return items
+	}
+
+	var kept []Item
+	for _, item := range items {
+		text := strings.ToLower(item.Title + "\n" + item.Description)
+		if len(opts.Contains) > 0 && !containsAny(text, opts.Contains) {
+			continue
+		}
+		if containsAny(text, opts.Excludes) {
+			continue
+		}
+		if opts.Match != nil && !opts.Match.MatchString(matchText(item, opts.MatchField)) {
+			continue
+		}
+		kept = append(kept, item)
+	}
+	return kept
+}
+
+// withinRange keeps items starting inside opts.After and opts.Before. When
+// either bound is set, items without a date are dropped.
+func withinRange(items []scheduledItem, opts CalendarOptions) []scheduledItem {
+	if opts.After.IsZero() && opts.Before.IsZero() {
+		return items
+	}
+
+	var kept []scheduledItem
+	for _, item := range items {
+		if !item.dated {
+			continue
+		}
+		if !opts.After.IsZero() && item.startTime.Before(opts.After) {
+			continue
+		}
+		if !opts.Before.IsZero() && !item.startTime.Before(opts.Before) {
+			continue
+		}
+		kept = append(kept, item)
+	}
+	return kept
+}
+
+func containsAny(text string, terms []string) bool {
+	for _, term := range terms {
+		if strings.Contains(text, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchText returns the part of an item a match pattern is applied to.
+func matchText(item Item, field string) string {
+	switch field {
+	case "description":
+		return item.Description
+	case "all":
+		return item.Title + "\n" + item.Description
+	default:
+		return item.Title
+	}
+}
+
+// splitTerms parses a comma-separated list of filter terms, lowercased for
+// case-insensitive matching.
+func splitTerms(raw string) []string {
+	var terms []string
+	for _, term := range strings.Split(raw, ",") {
+		if term = strings.ToLower(strings.TrimSpace(term)); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Mock feed mixing press releases and webinars
+const mockMixedFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+  <channel>
+    <title>Company News</title>
+    <item>
+      <title>Press Release: Q3 Results</title>
+      <description>Quarterly earnings</description>
+      <guid>press-1</guid>
+    </item>
+    <item>
+      <title>Upcoming WEBINAR on Security</title>
+      <description>Join our live session</description>
+      <guid>webinar-1</guid>
+    </item>
+    <item>
+      <title>Product Launch</title>
+      <description>Recorded webinar now available</description>
+      <guid>webinar-2</guid>
+    </item>
+    <item>
+      <title>Workshop: Go Basics</title>
+      <description>Hands-on workshop (cancelled)</description>
+      <guid>workshop-1</guid>
+    </item>
+  </channel>
+</rss>`
+
+func TestFilterItems(t *testing.T) {
+	rss, err := parseRSS([]byte(mockMixedFeed))
+	if err != nil {
+		t.Fatalf("Failed to parse feed: %v", err)
+	}
+
+	tests := []struct {
+		contains string
+		excludes string
+		expected []string
+	}{
+		{"", "", []string{"press-1", "webinar-1", "webinar-2", "workshop-1"}},
+		{"webinar", "", []string{"webinar-1", "webinar-2"}},
+		{"Webinar, workshop", "", []string{"webinar-1", "webinar-2", "workshop-1"}},
+		{"webinar,workshop", "recorded,cancelled", []string{"webinar-1"}},
+		{"", "press", []string{"webinar-1", "webinar-2", "workshop-1"}},
+	}
+
+	for _, test := range tests {
+		opts := defaultCalendarOptions()
+		opts.Contains = splitTerms(test.contains)
+		opts.Excludes = splitTerms(test.excludes)
+
+		var guids []string
+		for _, item := range filterItems(rss.Channel.Items, opts) {
+			guids = append(guids, item.GUID)
+		}
+		if strings.Join(guids, ",") != strings.Join(test.expected, ",") {
+			t.Errorf("contains=%q excludes=%q kept %v, expected %v", test.contains, test.excludes, guids, test.expected)
+		}
+	}
+}
+
+func TestCalendarHandlerContainsFilter(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(mockMixedFeed))
+	}))
+	defer mockServer.Close()
+
+	// Clear cache for clean test
+	cache = &Cache{}
+
+	query := url.Values{"url": {mockServer.URL}, "contains": {"webinar"}}
+	req := httptest.NewRequest("GET", "/calendar?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+
+	calendarHandler(w, req)
+
+	body := w.Body.String()
+	if count := strings.Count(body, "BEGIN:VEVENT"); count != 2 {
+		t.Errorf("Expected 2 matching VEVENTs, got %d", count)
+	}
+	if strings.Contains(body, "UID:press-1") {
+		t.Errorf("Expected press release to be filtered out, got: %s", body)
+	}
+}
+
+func TestFilterItemsMatch(t *testing.T) {
+	items := []Item{
+		{Title: "Release v1.2", Description: "Bug fixes", GUID: "release-1"},
+		{Title: "Release notes draft", Description: "See Release v2.0 soon", GUID: "draft"},
+		{Title: "Release v10.4", Description: "Features", GUID: "release-2"},
+	}
+
+	tests := []struct {
+		pattern  string
+		field    string
+		expected []string
+	}{
+		{`Release v\d+\.\d+`, "title", []string{"release-1", "release-2"}},
+		{`^Hotfix`, "title", nil},
+		{`Release v\d+\.\d+`, "description", []string{"draft"}},
+		{`Release v\d+\.\d+`, "all", []string{"release-1", "draft", "release-2"}},
+	}
+
+	for _, test := range tests {
+		query := url.Values{"match": {test.pattern}, "matchfield": {test.field}}
+		opts, err := parseCalendarOptions(query)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", test.pattern, err)
+		}
+
+		var guids []string
+		for _, item := range filterItems(items, opts) {
+			guids = append(guids, item.GUID)
+		}
+		if strings.Join(guids, ",") != strings.Join(test.expected, ",") {
+			t.Errorf("match=%q matchfield=%s kept %v, expected %v", test.pattern, test.field, guids, test.expected)
+		}
+	}
+}
+
+func TestCalendarHandlerMalformedMatch(t *testing.T) {
+	query := url.Values{"url": {"https://test.com"}, "match": {"Release (v"}}
+	req := httptest.NewRequest("GET", "/calendar?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+
+	calendarHandler(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code 400, got %d", w.Code)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "missing closing )") {
+		t.Errorf("Expected compile error in body, got '%s'", body)
+	}
+}
+
+func TestCalendarItemsWindow(t *testing.T) {
+	now := time.Now()
+	items := []Item{
+		{Title: "Recent", GUID: "recent", PubDate: now.AddDate(0, 0, -10).Format(time.RFC1123Z)},
+		{Title: "Old", GUID: "old", PubDate: now.AddDate(0, 0, -200).Format(time.RFC1123Z)},
+		{Title: "Undated", GUID: "undated"},
+	}
+	rss := &RSS{Channel: Channel{Items: items}}
+
+	opts, err := parseCalendarOptions(url.Values{"window": {"90d"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var guids []string
+	for _, item := range calendarItems(rss, opts) {
+		guids = append(guids, item.GUID)
+	}
+	if strings.Join(guids, ",") != "recent" {
+		t.Errorf("window=90d kept %v, expected [recent]", guids)
+	}
+}
+
+func TestCalendarItemsAfterBefore(t *testing.T) {
+	items := []Item{
+		{Title: "January", GUID: "jan", PubDate: "Mon, 15 Jan 2024 10:00:00 +0000"},
+		{Title: "March", GUID: "mar", PubDate: "Fri, 15 Mar 2024 10:00:00 +0000"},
+		{Title: "June", GUID: "jun", PubDate: "Sat, 15 Jun 2024 10:00:00 +0000"},
+	}
+	rss := &RSS{Channel: Channel{Items: items}}
+
+	tests := []struct {
+		query    url.Values
+		expected string
+	}{
+		{url.Values{"after": {"2024-03-01"}}, "mar,jun"},
+		{url.Values{"after": {"2024-03-15T10:00:00Z"}}, "mar,jun"},
+		{url.Values{"before": {"2024-03-15T10:00:00Z"}}, "jan"},
+		{url.Values{"after": {"2024-02-01"}, "before": {"2024-04-01"}}, "mar"},
+	}
+
+	for _, test := range tests {
+		opts, err := parseCalendarOptions(test.query)
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v", test.query, err)
+		}
+
+		var guids []string
+		for _, item := range calendarItems(rss, opts) {
+			guids = append(guids, item.GUID)
+		}
+		if strings.Join(guids, ",") != test.expected {
+			t.Errorf("%s kept %v, expected %s", test.query.Encode(), guids, test.expected)
+		}
+	}
+}
+
+func TestCalendarItemsUpcoming(t *testing.T) {
+	now := time.Now()
+	items := []Item{
+		{Title: "Past", GUID: "past", PubDate: now.AddDate(0, 0, -3).Format(time.RFC1123Z)},
+		{Title: "In Progress", GUID: "started", PubDate: now.Add(-30 * time.Minute).Format(time.RFC1123Z)},
+		{Title: "Next Week", GUID: "soon", PubDate: now.AddDate(0, 0, 7).Format(time.RFC1123Z)},
+		{Title: "Next Year", GUID: "later", PubDate: now.AddDate(1, 0, 0).Format(time.RFC1123Z)},
+	}
+	rss := &RSS{Channel: Channel{Items: items}}
+
+	tests := []struct {
+		query    url.Values
+		expected string
+	}{
+		{url.Values{"upcoming": {"true"}}, "started,soon,later"},
+		{url.Values{"upcoming": {"false"}}, "past,started,soon,later"},
+		// With upcoming, window looks ahead
+		{url.Values{"upcoming": {"true"}, "window": {"30d"}}, "started,soon"},
+		{url.Values{"upcoming": {"true"}, "after": {now.AddDate(0, 0, 1).Format("2006-01-02")}}, "soon,later"},
+	}
+
+	for _, test := range tests {
+		opts, err := parseCalendarOptions(test.query)
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v", test.query, err)
+		}
+
+		var guids []string
+		for _, item := range calendarItems(rss, opts) {
+			guids = append(guids, item.GUID)
+		}
+		if strings.Join(guids, ",") != test.expected {
+			t.Errorf("%s kept %v, expected %s", test.query.Encode(), guids, test.expected)
+		}
+	}
+
+	if _, err := parseCalendarOptions(url.Values{"upcoming": {"soon"}}); err == nil {
+		t.Error("Expected an error for an invalid upcoming value")
+	}
+}
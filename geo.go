@@ -0,0 +1,59 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	geoNamespace    = "http://www.w3.org/2003/01/geo/wgs84_pos#"
+	geoRSSNamespace = "http://www.georss.org/georss"
+)
+
+// geo returns the item's coordinates from W3C <geo:lat>/<geo:long>, a
+// GeoRSS <georss:point> or the geo attribute of <podcast:location>,
+// whichever parses first.
+func (i Item) geo() (lat, lon float64, ok bool) {
+	var rawLat, rawLon, point string
+	for _, extra := range i.Extra {
+		switch {
+		case extra.XMLName.Space == geoNamespace && extra.XMLName.Local == "lat":
+			rawLat = extra.Value
+		case extra.XMLName.Space == geoNamespace && extra.XMLName.Local == "long":
+			rawLon = extra.Value
+		case extra.XMLName.Space == geoRSSNamespace && extra.XMLName.Local == "point":
+			point = extra.Value
+		}
+	}
+
+	if lat, lon, ok := parseCoordinates(rawLat, rawLon); ok {
+		return lat, lon, true
+	}
+	// A GeoRSS point is "lat lon" separated by whitespace
+	if fields := strings.Fields(point); len(fields) == 2 {
+		if lat, lon, ok := parseCoordinates(fields[0], fields[1]); ok {
+			return lat, lon, true
+		}
+	}
+	return i.podcastGeo()
+}
+
+// parseCoordinates parses decimal degrees, rejecting out-of-range values and
+// NaN, which no range check catches.
+func parseCoordinates(rawLat, rawLon string) (lat, lon float64, ok bool) {
+	lat, err := strconv.ParseFloat(strings.TrimSpace(rawLat), 64)
+	if err != nil || math.IsNaN(lat) || lat < -90 || lat > 90 {
+		return 0, 0, false
+	}
+	lon, err = strconv.ParseFloat(strings.TrimSpace(rawLon), 64)
+	if err != nil || math.IsNaN(lon) || lon < -180 || lon > 180 {
+		return 0, 0, false
+	}
+	return lat, lon, true
+}
+
+// formatCoordinate writes a coordinate without trailing zeros.
+func formatCoordinate(degrees float64) string {
+	return strconv.FormatFloat(degrees, 'f', -1, 64)
+}
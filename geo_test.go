@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRSSToICalGeo(t *testing.T) {
+	feed := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:georss="http://www.georss.org/georss" xmlns:geo="http://www.w3.org/2003/01/geo/wgs84_pos#">
+  <channel>
+    <title>Incidents</title>
+    <item>
+      <title>GeoRSS point</title>
+      <georss:point>37.77 -122.41</georss:point>
+      <guid>point</guid>
+    </item>
+    <item>
+      <title>W3C geo</title>
+      <geo:lat>51.5074</geo:lat>
+      <geo:long>-0.1278</geo:long>
+      <guid>latlong</guid>
+    </item>
+    <item>
+      <title>Out of range</title>
+      <georss:point>137.77 -122.41</georss:point>
+      <guid>invalid</guid>
+    </item>
+    <item>
+      <title>Not a number</title>
+      <georss:point>NaN NaN</georss:point>
+      <guid>nan</guid>
+    </item>
+  </channel>
+</rss>`
+
+	rss, err := parseRSS([]byte(feed))
+	if err != nil {
+		t.Fatalf("Failed to parse feed: %v", err)
+	}
+
+	ical, err := rssToICal(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+	for _, geo := range []string{"GEO:37.77;-122.41", "GEO:51.5074;-0.1278"} {
+		if !strings.Contains(ical, geo) {
+			t.Errorf("Expected %s, got: %s", geo, ical)
+		}
+	}
+	if count := strings.Count(ical, "GEO:"); count != 2 {
+		t.Errorf("Expected GEO only on items with valid coordinates, got %d", count)
+	}
+}
+
+func TestParseCoordinates(t *testing.T) {
+	tests := []struct {
+		lat, lon string
+		valid    bool
+	}{
+		{"37.77", "-122.41", true},
+		{" 0 ", "0", true},
+		{"90.1", "0", false},
+		{"0", "-180.5", false},
+		{"north", "0", false},
+		{"", "", false},
+		{"NaN", "NaN", false},
+		{"0", "NaN", false},
+	}
+
+	for _, test := range tests {
+		if _, _, ok := parseCoordinates(test.lat, test.lon); ok != test.valid {
+			t.Errorf("parseCoordinates(%q, %q) ok = %v, expected %v", test.lat, test.lon, ok, test.valid)
+		}
+	}
+}
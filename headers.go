@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// forwardableHeaders are the header names a request may ask to have sent
+// upstream through header= parameters, in canonical form.
+var forwardableHeaders = map[string]bool{
+	"Accept-Language": true,
+	"Api-Key":         true,
+	"Authorization":   true,
+	"X-Api-Key":       true,
+	"X-Api-Token":     true,
+	"X-Auth-Token":    true,
+}
+
+// allowCookieHeader additionally permits forwarding a Cookie header.
+var allowCookieHeader = false
+
+type upstreamHeadersKey struct{}
+
+// parseUpstreamHeaders reads repeated "Name:value" header parameters,
+// rejecting names outside the allowlist.
+func parseUpstreamHeaders(values []string) (http.Header, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	header := http.Header{}
+	for _, value := range values {
+		name, content, ok := strings.Cut(value, ":")
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid header %q: use Name:value", value)
+		}
+		if !forwardableHeaders[name] && !(name == "Cookie" && allowCookieHeader) {
+			return nil, fmt.Errorf("header %s may not be sent upstream", name)
+		}
+		content = strings.TrimSpace(content)
+		if strings.ContainsAny(content, "\r\n") {
+			return nil, fmt.Errorf("invalid value for header %s", name)
+		}
+		header.Add(name, content)
+	}
+	return header, nil
+}
+
+// withUpstreamHeaders returns a context whose feed fetches send header.
+func withUpstreamHeaders(ctx context.Context, header http.Header) context.Context {
+	if len(header) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, upstreamHeadersKey{}, header)
+}
+
+// upstreamHeaders returns the extra headers feed fetches under ctx send.
+func upstreamHeaders(ctx context.Context) http.Header {
+	header, _ := ctx.Value(upstreamHeadersKey{}).(http.Header)
+	return header
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestCalendarHandlerForwardsHeaders(t *testing.T) {
+	var apiKey string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey = r.Header.Get("X-API-Key")
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(mockRSSFeed))
+	}))
+	defer mockServer.Close()
+
+	// Clear cache for clean test
+	cache = &Cache{}
+	failures = &NegativeCache{}
+
+	query := url.Values{"url": {mockServer.URL}, "header": {"X-API-Key: secret"}}
+	req := httptest.NewRequest("GET", "/calendar?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+	calendarHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", w.Code)
+	}
+	if apiKey != "secret" {
+		t.Errorf("Expected X-API-Key 'secret' upstream, got '%s'", apiKey)
+	}
+}
+
+func TestCalendarHandlerHeadersNotForwardedAcrossHosts(t *testing.T) {
+	var apiKey, gotUserAgent string
+	otherServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey = r.Header.Get("X-API-Key")
+		gotUserAgent = r.Header.Get("User-Agent")
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(mockRSSFeed))
+	}))
+	defer otherServer.Close()
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, otherServer.URL+"/feed.xml", http.StatusFound)
+	}))
+	defer mockServer.Close()
+
+	// Clear cache for clean test
+	cache = &Cache{}
+	failures = &NegativeCache{}
+
+	query := url.Values{"url": {mockServer.URL}, "header": {"X-API-Key: secret"}}
+	req := httptest.NewRequest("GET", "/calendar?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+	calendarHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", w.Code)
+	}
+	if apiKey != "" {
+		t.Errorf("Expected no X-API-Key sent to another host, got '%s'", apiKey)
+	}
+	if gotUserAgent != userAgent {
+		t.Errorf("Expected our User-Agent on the redirect, got '%s'", gotUserAgent)
+	}
+}
+
+func TestCalendarHandlerRejectsDisallowedHeader(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected no upstream request for a disallowed header")
+	}))
+	defer mockServer.Close()
+
+	// Clear cache for clean test
+	cache = &Cache{}
+
+	query := url.Values{"url": {mockServer.URL}, "header": {"Host: internal"}}
+	req := httptest.NewRequest("GET", "/calendar?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+	calendarHandler(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code 400, got %d", w.Code)
+	}
+}
+
+func TestParseUpstreamHeaders(t *testing.T) {
+	header, err := parseUpstreamHeaders([]string{"x-api-key:one", "Accept-Language: de"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if header.Get("X-Api-Key") != "one" || header.Get("Accept-Language") != "de" {
+		t.Errorf("Unexpected headers: %v", header)
+	}
+
+	for _, raw := range []string{"X-API-Key", "Host:example.com", "Cookie:session=1", ":value"} {
+		if _, err := parseUpstreamHeaders([]string{raw}); err == nil {
+			t.Errorf("Expected error for header %q", raw)
+		}
+	}
+
+	defer func(allow bool) { allowCookieHeader = allow }(allowCookieHeader)
+	allowCookieHeader = true
+	if _, err := parseUpstreamHeaders([]string{"Cookie:session=1"}); err != nil {
+		t.Errorf("Expected Cookie to be allowed when enabled, got: %v", err)
+	}
+}
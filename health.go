@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// readyTimeout bounds how long /ready waits on the canary feed.
+const readyTimeout = 5 * time.Second
+
+// healthcheckURL is the canary feed /ready fetches to confirm outbound
+// connectivity. When empty the readiness check always passes.
+var healthcheckURL string
+
+// readyHandler reports whether the server can reach upstream feeds, unlike
+// /health which only reports that the process is serving.
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	if healthcheckURL == "" {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	if _, err := fetchRSS(ctx, healthcheckURL); err != nil {
+		loggerFrom(ctx).Warn("readiness check failed", "error", err)
+		http.Error(w, "Healthcheck feed unreachable", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadyHandler(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(mockRSSFeed))
+	}))
+	defer mockServer.Close()
+
+	defer func(url string) { healthcheckURL = url }(healthcheckURL)
+	healthcheckURL = mockServer.URL
+
+	w := httptest.NewRecorder()
+	readyHandler(w, httptest.NewRequest("GET", "/ready", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code 200, got %d", w.Code)
+	}
+}
+
+func TestReadyHandlerUnreachable(t *testing.T) {
+	mockServer := httptest.NewServer(http.NotFoundHandler())
+	mockServer.Close()
+
+	defer func(url string) { healthcheckURL = url }(healthcheckURL)
+	healthcheckURL = mockServer.URL
+
+	w := httptest.NewRecorder()
+	readyHandler(w, httptest.NewRequest("GET", "/ready", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status code 503, got %d", w.Code)
+	}
+}
+
+func TestReadyHandlerWithoutCanary(t *testing.T) {
+	defer func(url string) { healthcheckURL = url }(healthcheckURL)
+	healthcheckURL = ""
+
+	w := httptest.NewRecorder()
+	readyHandler(w, httptest.NewRequest("GET", "/ready", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code 200, got %d", w.Code)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// allowedHosts restricts which hosts feeds may be fetched from. An empty
+// list allows any host.
+var allowedHosts []string
+
+// allowPrivateNetworks permits fetching feeds from loopback, private and
+// link-local addresses, which are otherwise refused so the server can't be
+// used to probe internal services.
+var allowPrivateNetworks = false
+
+// errPrivateAddress is returned when a feed host resolves to an address
+// that allowPrivateNetworks doesn't permit.
+var errPrivateAddress = errors.New("feed host resolves to a private address")
+
+// fetchClient is shared by all upstream fetches so connections, including
+// HTTP/2 ones, are kept alive and reused across requests to popular hosts.
+// Each attempt is bounded by fetchTimeout through its context rather than
+// Client.Timeout, which would be fixed before the config is read.
+var fetchClient = &http.Client{
+	Transport:     newFetchTransport(),
+	CheckRedirect: checkRedirect,
+}
+
+// Connection pool sizes for fetchClient. Feeds often cluster on a few hosts
+// (feedburner, YouTube, Substack), so more idle connections per host are kept
+// than net/http's default of two.
+const (
+	fetchMaxIdleConns        = 100
+	fetchMaxIdleConnsPerHost = 10
+	fetchIdleConnTimeout     = 90 * time.Second
+)
+
+// newFetchTransport returns the transport for upstream fetches. Its dialer
+// checks the resolved address, so hostnames pointing at internal IPs are
+// caught too. It never uses the environment's proxy, which would dial the
+// proxy instead of the feed and skip that check.
+func newFetchTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   dialControl,
+	}
+	transport.DialContext = dialer.DialContext
+	transport.ForceAttemptHTTP2 = true
+	transport.MaxIdleConns = fetchMaxIdleConns
+	transport.MaxIdleConnsPerHost = fetchMaxIdleConnsPerHost
+	transport.IdleConnTimeout = fetchIdleConnTimeout
+	return transport
+}
+
+// dialControl refuses connections to private addresses unless they are
+// explicitly permitted.
+func dialControl(network, address string, _ syscall.RawConn) error {
+	if allowPrivateNetworks {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip != nil && isPrivateIP(ip) {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+// isPrivateIP reports whether ip is loopback, private, link-local or
+// unspecified.
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast()
+}
+
+// normalizeFeedURL checks that raw is an http or https URL, assuming https
+// when no scheme is given so inputs like example.com/feed.xml work.
+func normalizeFeedURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		// "example.com:8080/feed" parses as scheme "example.com", so a port
+		// after the colon also means the scheme was left out
+		if parsed, err := url.Parse(raw); err != nil || parsed.Scheme == "" ||
+			(parsed.Opaque != "" && parsed.Opaque[0] >= '0' && parsed.Opaque[0] <= '9') {
+			raw = "https://" + raw
+		}
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid feed URL %q", raw)
+	}
+	switch strings.ToLower(parsed.Scheme) {
+	case "http", "https":
+	default:
+		return "", fmt.Errorf("unsupported feed URL scheme %q: use http or https", parsed.Scheme)
+	}
+	if parsed.Host == "" {
+		return "", fmt.Errorf("invalid feed URL %q: missing host", raw)
+	}
+	return parsed.String(), nil
+}
+
+// redactURL masks any password in rawURL so it can be logged.
+func redactURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.User == nil {
+		return rawURL
+	}
+	return parsed.Redacted()
+}
+
+// hostAllowed reports whether rawURL's host is in allowedHosts, either
+// exactly or as a subdomain of an entry.
+func hostAllowed(rawURL string) bool {
+	if len(allowedHosts) == 0 {
+		return true
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, allowed := range allowedHosts {
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitHosts parses a comma-separated host list, lowercasing each entry.
+func splitHosts(raw string) []string {
+	var hosts []string
+	for _, host := range strings.Split(raw, ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// lineBreakElements start or end a line of text when converting HTML.
+var lineBreakElements = map[string]bool{
+	"br": true, "p": true, "div": true, "li": true, "tr": true,
+	"ul": true, "ol": true, "table": true, "blockquote": true, "pre": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// htmlToText converts an HTML fragment to plain text for calendar clients
+// that render DESCRIPTION verbatim. Entities are decoded, block-level
+// elements become line breaks and runs of whitespace are collapsed.
+func htmlToText(s string) string {
+	var b strings.Builder
+	tokenizer := html.NewTokenizer(strings.NewReader(s))
+	skipDepth := 0 // inside <script> or <style>
+
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			return collapseWhitespace(b.String())
+		case html.TextToken:
+			if skipDepth == 0 {
+				b.Write(tokenizer.Text())
+			}
+		case html.StartTagToken, html.SelfClosingTagToken, html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			tag := string(name)
+			if tag == "script" || tag == "style" {
+				if tokenType == html.StartTagToken {
+					skipDepth++
+				} else if tokenType == html.EndTagToken && skipDepth > 0 {
+					skipDepth--
+				}
+				continue
+			}
+			if lineBreakElements[tag] {
+				b.WriteByte('\n')
+			}
+		}
+	}
+}
+
+// cleanTitle reduces a feed title to one line of plain text. CDATA titles
+// often carry surrounding whitespace, markup or entities left escaped inside
+// the CDATA section, none of which belong in a SUMMARY.
+func cleanTitle(s string) string {
+	if containsMarkup(s) {
+		s = htmlToText(s)
+	} else {
+		s = html.UnescapeString(s)
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// collapseWhitespace squeezes runs of whitespace within each line to a single
+// space and drops blank lines.
+func collapseWhitespace(s string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// containsMarkup reports whether s has any HTML tags, as opposed to plain
+// text that may still contain entities.
+func containsMarkup(s string) bool {
+	tokenizer := html.NewTokenizer(strings.NewReader(s))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return false
+		case html.StartTagToken, html.SelfClosingTagToken, html.EndTagToken:
+			return true
+		}
+	}
+}
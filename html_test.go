@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"<p>Hello <b>World</b>&amp;more</p>", "Hello World&more"},
+		{"Plain text description", "Plain text description"},
+		{"Line one<br>Line two<br/>Line three", "Line one\nLine two\nLine three"},
+		{"<div><p>Nested <em>tags <strong>here</strong></em></p></div>", "Nested tags here"},
+		{"Non&nbsp;breaking   and\t tabs", "Non breaking and tabs"},
+		{"<p>First</p><p>Second</p>", "First\nSecond"},
+		{"<style>p { color: red; }</style>Styled<script>alert(1)</script>", "Styled"},
+	}
+
+	for _, test := range tests {
+		if result := htmlToText(test.input); result != test.expected {
+			t.Errorf("htmlToText(%q) = %q, expected %q", test.input, result, test.expected)
+		}
+	}
+}
+
+func TestContainsMarkup(t *testing.T) {
+	tests := map[string]bool{
+		"<p>Hello</p>":     true,
+		"Line one<br/>two": true,
+		"Plain text":       false,
+		"Fish &amp; Chips": false,
+		"":                 false,
+		"1 < 2 and 3 > 2":  false,
+	}
+	for input, expected := range tests {
+		if got := containsMarkup(input); got != expected {
+			t.Errorf("containsMarkup(%q) = %v, expected %v", input, got, expected)
+		}
+	}
+}
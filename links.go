@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// resolveLinks makes relative item links such as "/posts/123" absolute,
+// resolving them against the channel's <link>, which is itself resolved
+// against feedURL when given. Without an absolute base, links are left as
+// they are.
+func (r *RSS) resolveLinks(feedURL *url.URL) {
+	base := feedURL
+	if channelLink := strings.TrimSpace(r.Channel.Link); channelLink != "" {
+		if link, err := url.Parse(channelLink); err == nil {
+			if feedURL != nil {
+				link = feedURL.ResolveReference(link)
+			}
+			if link.IsAbs() {
+				base = link
+				r.Channel.Link = link.String()
+			}
+		}
+	}
+	if base == nil || !base.IsAbs() {
+		return
+	}
+
+	for i := range r.Channel.Items {
+		item := &r.Channel.Items[i]
+		link := strings.TrimSpace(item.Link)
+		if link == "" {
+			continue
+		}
+		if ref, err := url.Parse(link); err == nil && !ref.IsAbs() {
+			item.Link = base.ResolveReference(ref).String()
+		}
+	}
+}
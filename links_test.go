@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRSSToICalRelativeLinks(t *testing.T) {
+	feed := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">
+  <channel>
+    <title>Blog</title>
+    <link>https://example.com/blog/</link>
+    <atom:link href="https://example.com/blog/feed.xml" rel="self" type="application/rss+xml"/>
+    <item>
+      <title>Root relative</title>
+      <link>/posts/123</link>
+      <guid>123</guid>
+    </item>
+    <item>
+      <title>Path relative</title>
+      <link>posts/124?ref=rss</link>
+      <guid>124</guid>
+    </item>
+    <item>
+      <title>Absolute</title>
+      <link>https://elsewhere.example.org/125</link>
+      <guid>125</guid>
+    </item>
+  </channel>
+</rss>`
+
+	rss, err := parseRSS([]byte(feed))
+	if err != nil {
+		t.Fatalf("Failed to parse feed: %v", err)
+	}
+	if rss.Channel.Link != "https://example.com/blog/" {
+		t.Errorf("Expected the channel <link> despite <atom:link>, got %q", rss.Channel.Link)
+	}
+
+	ical, err := rssToICal(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+	for _, exp := range []string{
+		"URL:https://example.com/posts/123\r\n",
+		"URL:https://example.com/blog/posts/124?ref=rss\r\n",
+		"URL:https://elsewhere.example.org/125\r\n",
+	} {
+		if !strings.Contains(ical, exp) {
+			t.Errorf("Expected iCal to contain %q, got: %s", exp, ical)
+		}
+	}
+}
+
+func TestFetchRSSResolvesLinksAgainstFeedURL(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(`<rss version="2.0"><channel><title>Relative</title><link>/site/</link>` +
+			`<item><title>Post</title><link>post/1</link></item>` +
+			`<item><title>Root relative</title><link>/standalone</link></item></channel></rss>`))
+	}))
+	defer mockServer.Close()
+
+	rss, err := fetchRSS(context.Background(), mockServer.URL+"/feeds/main.xml")
+	if err != nil {
+		t.Fatalf("Failed to fetch feed: %v", err)
+	}
+	if got := rss.Channel.Link; got != mockServer.URL+"/site/" {
+		t.Errorf("Expected the channel link resolved against the feed URL, got %q", got)
+	}
+	for i, exp := range []string{mockServer.URL + "/site/post/1", mockServer.URL + "/standalone"} {
+		if got := rss.Channel.Items[i].Link; got != exp {
+			t.Errorf("Expected item %d link %q, got %q", i, exp, got)
+		}
+	}
+}
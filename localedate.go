@@ -0,0 +1,79 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// leadingWeekdayPattern matches the weekday a date starts with, with the
+	// period of abbreviations such as French "mer." and its comma.
+	leadingWeekdayPattern = regexp.MustCompile(`^\s*(\p{L}+)\.?,?\s*`)
+	// localizedWordPattern matches a word of a date, with its period.
+	localizedWordPattern = regexp.MustCompile(`\p{L}+\.?`)
+)
+
+// localizedWeekdays maps German, French and Spanish weekday names and their
+// abbreviations, in lowercase, to the English abbreviations of RFC 822.
+var localizedWeekdays = map[string]string{
+	// German
+	"mo": "Mon", "montag": "Mon", "di": "Tue", "dienstag": "Tue",
+	"mi": "Wed", "mittwoch": "Wed", "do": "Thu", "donnerstag": "Thu",
+	"fr": "Fri", "freitag": "Fri", "sa": "Sat", "samstag": "Sat",
+	"so": "Sun", "sonntag": "Sun",
+	// French
+	"lun": "Mon", "lundi": "Mon", "mar": "Tue", "mardi": "Tue",
+	"mer": "Wed", "mercredi": "Wed", "jeu": "Thu", "jeudi": "Thu",
+	"ven": "Fri", "vendredi": "Fri", "sam": "Sat", "samedi": "Sat",
+	"dim": "Sun", "dimanche": "Sun",
+	// Spanish
+	"lunes": "Mon", "mié": "Wed", "miércoles": "Wed",
+	"jue": "Thu", "jueves": "Thu", "vie": "Fri", "viernes": "Fri",
+	"sáb": "Sat", "sábado": "Sat", "dom": "Sun", "domingo": "Sun",
+	"martes": "Tue",
+}
+
+// localizedMonths maps German, French and Spanish month names and their
+// abbreviations, in lowercase, to English abbreviations.
+var localizedMonths = map[string]string{
+	// German
+	"januar": "Jan", "februar": "Feb", "märz": "Mar", "mär": "Mar",
+	"mrz": "Mar", "april": "Apr", "mai": "May", "juni": "Jun",
+	"juli": "Jul", "august": "Aug", "september": "Sep", "okt": "Oct",
+	"oktober": "Oct", "november": "Nov", "dez": "Dec", "dezember": "Dec",
+	// French
+	"janv": "Jan", "janvier": "Jan", "févr": "Feb", "fév": "Feb",
+	"février": "Feb", "mars": "Mar", "avr": "Apr", "avril": "Apr",
+	"juin": "Jun", "juil": "Jul", "juillet": "Jul", "août": "Aug",
+	"sept": "Sep", "septembre": "Sep", "octobre": "Oct", "novembre": "Nov",
+	"déc": "Dec", "décembre": "Dec",
+	// Spanish
+	"ene": "Jan", "enero": "Jan", "febrero": "Feb", "marzo": "Mar",
+	"abr": "Apr", "abril": "Apr", "mayo": "May", "junio": "Jun",
+	"julio": "Jul", "ago": "Aug", "agosto": "Aug", "septiembre": "Sep",
+	"octubre": "Oct", "noviembre": "Nov", "dic": "Dec", "diciembre": "Dec",
+}
+
+// englishDate rewrites the localized weekday and month names of a date,
+// as in German "Mi, 27 Jul 2025" or French "mer. 27 juil. 2025", in English,
+// so the layouts of dateFormats can parse it. Only a leading word is read as
+// a weekday, which keeps French "mar." (Tuesday) apart from months. It
+// reports whether anything was rewritten.
+func englishDate(date string) (string, bool) {
+	rewritten := false
+	if match := leadingWeekdayPattern.FindStringSubmatchIndex(date); match != nil {
+		if weekday, ok := localizedWeekdays[strings.ToLower(date[match[2]:match[3]])]; ok {
+			date = weekday + ", " + date[match[1]:]
+			rewritten = true
+		}
+	}
+
+	english := localizedWordPattern.ReplaceAllStringFunc(date, func(word string) string {
+		if month, ok := localizedMonths[strings.ToLower(strings.TrimSuffix(word, "."))]; ok {
+			rewritten = true
+			return month
+		}
+		return word
+	})
+	return english, rewritten
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeLocalized(t *testing.T) {
+	noon := time.Date(2025, time.July, 27, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		input    string
+		expected time.Time
+	}{
+		// German
+		{"Mi, 27 Jul 2025 12:00:00 +0200", noon},
+		{"So, 27 Juli 2025 12:00:00 +0200", noon},
+		{"Mittwoch, 27 Jul 2025 12:00 +0200", noon},
+		{"Do, 06 Mär 2025 12:00:00 +0100", time.Date(2025, time.March, 6, 11, 0, 0, 0, time.UTC)},
+		{"Mo, 1 Dez 2025 12:00:00 +0100", time.Date(2025, time.December, 1, 11, 0, 0, 0, time.UTC)},
+		{"Mi, 27 August 2025 10:00:00 +0200", time.Date(2025, time.August, 27, 8, 0, 0, 0, time.UTC)},
+		// French
+		{"dim., 27 juil. 2025 12:00:00 +0200", noon},
+		{"mer. 27 juillet 2025 12:00:00 +0200", noon},
+		{"mar., 05 août 2025 12:00:00 +0200", time.Date(2025, time.August, 5, 10, 0, 0, 0, time.UTC)},
+		{"27 févr. 2025 12:00:00 +0100", time.Date(2025, time.February, 27, 11, 0, 0, 0, time.UTC)},
+		{"Lundi, 1 Décembre 2025 12:00 +0100", time.Date(2025, time.December, 1, 11, 0, 0, 0, time.UTC)},
+		// Spanish
+		{"mié, 27 jul 2025 12:00:00 +0200", noon},
+		{"dom, 27 jul 2025 12:00:00 +0200", noon},
+		// Comma decimal seconds
+		{"2025-07-27T12:00:00,5+02:00", noon.Add(500 * time.Millisecond)},
+	}
+
+	for _, test := range tests {
+		result, err := parseTime(test.input)
+		if err != nil {
+			t.Errorf("Expected %q to parse, got error: %v", test.input, err)
+			continue
+		}
+		if !result.Equal(test.expected) {
+			t.Errorf("Expected %q to parse as %v, got %v", test.input, test.expected, result)
+		}
+	}
+
+	for _, input := range []string{"Mi, 27 Xyz 2025 12:00:00 +0200", "Blah, 27 juil. 2025"} {
+		if _, err := parseTime(input); err == nil {
+			t.Errorf("Expected an error for %q", input)
+		}
+	}
+}
+
+func TestEnglishDate(t *testing.T) {
+	if english, ok := englishDate("mar., 05 mars 2025 12:00:00 +0100"); !ok || english != "Tue, 05 Mar 2025 12:00:00 +0100" {
+		t.Errorf("Expected the weekday and month in English, got %q, %v", english, ok)
+	}
+	if _, ok := englishDate("Wed, 27 Jul 2025 12:00:00 +0200"); ok {
+		t.Error("Expected an English date to be left alone")
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCalendarHandlerLogsRequestID(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(mockRSSFeed))
+	}))
+	defer mockServer.Close()
+
+	// Clear cache for clean test
+	cache = &Cache{}
+	failures = &NegativeCache{}
+
+	var logs bytes.Buffer
+	req := httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil)
+	req.Header.Set("X-Request-ID", "trace-123")
+	req = req.WithContext(withLogger(req.Context(), newLogger(logFormatJSON, &logs)))
+	w := httptest.NewRecorder()
+	calendarHandler(w, req)
+
+	if got := w.Header().Get("X-Request-ID"); got != "trace-123" {
+		t.Errorf("Expected X-Request-ID trace-123, got '%s'", got)
+	}
+
+	var messages []string
+	var summary map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Expected JSON log line, got %q", line)
+		}
+		if record["request_id"] != "trace-123" {
+			t.Errorf("Expected request_id on every line, got %q", line)
+		}
+		messages = append(messages, record["msg"].(string))
+		if record["msg"] == "calendar request" {
+			summary = record
+		}
+	}
+
+	if !strings.Contains(strings.Join(messages, ","), "fetching feed") {
+		t.Errorf("Expected the fetch to log under the request, got %v", messages)
+	}
+	if summary == nil {
+		t.Fatalf("Expected a calendar request summary, got %v", messages)
+	}
+	if summary["url"] != mockServer.URL || summary["status"] != float64(200) || summary["cache_hit"] != false {
+		t.Errorf("Unexpected summary fields: %v", summary)
+	}
+	if _, ok := summary["duration_ms"].(float64); !ok {
+		t.Errorf("Expected duration_ms in summary, got %v", summary)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	req := httptest.NewRequest("GET", "/calendar", nil)
+	first, second := requestID(req), requestID(req)
+	if len(first) != 16 || first == second {
+		t.Errorf("Expected distinct generated IDs, got %q and %q", first, second)
+	}
+
+	req.Header.Set("X-Request-ID", "bad id\nwith newline")
+	if id := requestID(req); id == "bad id\nwith newline" {
+		t.Error("Expected a malformed X-Request-ID to be replaced")
+	}
+}
@@ -1,23 +1,92 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
 	"encoding/xml"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
-	"sync"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
+	"unicode"
 
 	ics "github.com/arran4/golang-ical"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/html/charset"
 )
 
 const (
-	defaultPort = "8080"
-	cacheTTL    = 5 * time.Minute
+	defaultPort     = "8080"
+	defaultCacheTTL = 5 * time.Minute
+
+	defaultCacheMaxEntries = 1000
+
+	defaultNegativeCacheTTL = 30 * time.Second
+
+	defaultCORSOrigin = "*"
+
+	defaultMaxRedirects = 10
+
+	defaultFetchTimeout = 30 * time.Second
+
+	defaultMaxFeedBytes = 10 << 20
+
+	defaultMaxItems = 5000
+
+	// shutdownTimeout is how long in-flight requests get to finish after a
+	// termination signal.
+	shutdownTimeout = 10 * time.Second
+
+	// icalLocalTimeFormat is a DATE-TIME without the UTC designator, used
+	// together with a TZID parameter.
+	icalLocalTimeFormat = "20060102T150405"
+
+	// listSeparator joins multi-valued TEXT properties such as CATEGORIES.
+	// golang-ical escapes every comma in TEXT values, so values are joined
+	// with a control character that can't occur in XML text and swapped for
+	// a comma as the calendar is serialized.
+	listSeparator = "\x1f"
 )
 
+// corsOrigin is sent as Access-Control-Allow-Origin on calendar responses. It
+// is configured from the CORS_ORIGIN environment variable at startup.
+var corsOrigin = defaultCORSOrigin
+
+// fetchTimeout bounds each upstream fetch attempt.
+var fetchTimeout = defaultFetchTimeout
+
+// maxFeedBytes caps the size of a feed body, protecting against oversized
+// or endless responses.
+var maxFeedBytes = defaultMaxFeedBytes
+
+// maxItems caps how many items of a feed are converted, guarding memory
+// against feeds with runaway item counts. Unlike the limit parameter it is
+// a server-wide safety valve.
+var maxItems = defaultMaxItems
+
+// maxRedirects is how many redirects a feed fetch follows before failing.
+var maxRedirects = defaultMaxRedirects
+
 type RSS struct {
 	XMLName xml.Name `xml:"rss"`
 	Channel Channel  `xml:"channel"`
@@ -26,7 +95,49 @@ type RSS struct {
 type Channel struct {
 	Title       string `xml:"title"`
 	Description string `xml:"description"`
+	TTL         int    `xml:"ttl"`
+	Image       Image  `xml:"image"`
 	Items       []Item `xml:"item"`
+	// ManagingEditor and Author name who is responsible for the feed,
+	// typically as "email (Name)"
+	ManagingEditor string `xml:"managingEditor"`
+	Author         string `xml:"author"`
+	// Links are the channel's <atom:link> elements, or an Atom feed's own
+	// links, such as rel="next" for the following page
+	Links []AtomLink `xml:"http://www.w3.org/2005/Atom link"`
+	// Link is the site the channel belongs to, the base of relative item
+	// links. It follows Links, which would otherwise lose <atom:link> to it
+	Link string `xml:"link"`
+}
+
+// truncateItems drops the items past limit, returning how many it dropped.
+func (c *Channel) truncateItems(limit int) int {
+	if len(c.Items) <= limit {
+		return 0
+	}
+	dropped := len(c.Items) - limit
+	c.Items = c.Items[:limit:limit]
+	return dropped
+}
+
+// Image is the channel's <image>, typically a logo.
+type Image struct {
+	URL   string `xml:"url"`
+	Title string `xml:"title"`
+	Link  string `xml:"link"`
+}
+
+// declaredTTL returns how long the channel asks to be cached via <ttl>, clamped
+// to maxFeedTTL, or zero when it doesn't say.
+func (c Channel) declaredTTL() time.Duration {
+	if c.TTL <= 0 {
+		return 0
+	}
+	ttl := time.Duration(c.TTL) * time.Minute
+	if ttl > maxFeedTTL {
+		return maxFeedTTL
+	}
+	return ttl
 }
 
 type Item struct {
@@ -34,376 +145,1548 @@ type Item struct {
 	Description string `xml:"description"`
 	Link        string `xml:"link"`
 	PubDate     string `xml:"pubDate"`
+	DCDate      string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Updated     string `xml:"http://www.w3.org/2005/Atom updated"`
+	BuildDate   string `xml:"lastBuildDate"`
 	GUID        string `xml:"guid"`
+	Author      string `xml:"author"`
+	// DCCreator is the <dc:creator> many blogs name post authors with
+	DCCreator string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	// PodcastLocation is the Podcasting 2.0 <podcast:location>, declared
+	// ahead of Location, which would otherwise take it without its geo
+	PodcastLocation PodcastLocation `xml:"https://podcastindex.org/namespace/1.0 location"`
+	Location        string          `xml:"location"`
+	Categories      []string        `xml:"category"`
+	Enclosures      []Enclosure     `xml:"enclosure"`
+	// ContentEncoded is the full HTML body WordPress and similar feeds put
+	// in <content:encoded>, with a summary in <description>
+	ContentEncoded string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	// ITunesDuration is the podcast episode length from <itunes:duration>
+	ITunesDuration string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+	// Media holds Media RSS thumbnails and content, used as the event image
+	Media
+	// Extra holds child elements without a dedicated field, so they can be
+	// selected by name at request time
+	Extra []ExtraElement `xml:",any"`
+}
+
+// Enclosure is a media file attached to an item, such as a podcast episode.
+type Enclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length int64  `xml:"length,attr"`
 }
 
-type CacheEntry struct {
-	data      string
-	timestamp time.Time
+type ExtraElement struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
 }
 
-type Cache struct {
-	entries map[string]CacheEntry
-	mu      sync.RWMutex
+// location returns the item's venue from the named element, defaulting to
+// <location>. A namespace prefix in field, as in "georss:point", is ignored.
+func (i Item) location(field string) string {
+	if _, local, ok := strings.Cut(field, ":"); ok {
+		field = local
+	}
+	if field == "" || field == "location" {
+		if location := strings.TrimSpace(i.Location); location != "" {
+			return location
+		}
+		return i.podcastLocation()
+	}
+
+	for _, extra := range i.Extra {
+		if extra.XMLName.Local == field {
+			return strings.TrimSpace(extra.Value)
+		}
+	}
+	return ""
 }
 
-func (c *Cache) Get(url string) (string, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+// categories returns the item's trimmed, de-duplicated categories.
+func (i Item) categories() []string {
+	var categories []string
+	seen := make(map[string]bool)
+	for _, category := range i.Categories {
+		category = strings.TrimSpace(category)
+		if category == "" || seen[category] {
+			continue
+		}
+		seen[category] = true
+		categories = append(categories, category)
+	}
+	return categories
+}
 
-	entry, exists := c.entries[url]
-	if !exists || time.Since(entry.timestamp) > cacheTTL {
-		return "", false
+// uid returns the item's GUID, or for items without one a stable identifier
+// derived from its title, link and publish date.
+func (i Item) uid() string {
+	if i.GUID != "" {
+		return i.GUID
 	}
-	return entry.data, true
+	sum := sha1.Sum([]byte(i.Title + "\n" + i.Link + "\n" + i.published()))
+	return hex.EncodeToString(sum[:])
 }
 
-func (c *Cache) Set(url, data string) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// duration returns the event length: the podcast episode length when the
+// item declares one, otherwise the requested duration.
+func (i Item) duration(opts CalendarOptions) time.Duration {
+	if d, ok := parseITunesDuration(i.ITunesDuration); ok {
+		return d
+	}
+	return opts.Duration
+}
 
-	if c.entries == nil {
-		c.entries = make(map[string]CacheEntry)
+// body returns the item's description: the full <content:encoded> text
+// when opts ask for it and the item has one, otherwise <description>.
+func (i Item) body(opts CalendarOptions) string {
+	if opts.FullDescription && strings.TrimSpace(i.ContentEncoded) != "" {
+		return i.ContentEncoded
 	}
-	c.entries[url] = CacheEntry{
-		data:      data,
-		timestamp: time.Now(),
+	return i.Description
+}
+
+// maxFallbackTitle is the length, in characters, a description is cut to
+// when it stands in for a missing title.
+const maxFallbackTitle = 80
+
+// fallbackTitle names an untitled item after the start of its description,
+// then its link, then "(untitled)".
+func fallbackTitle(description, link string) string {
+	if words := strings.Fields(description); len(words) > 0 {
+		return truncateWords(strings.Join(words, " "), maxFallbackTitle)
+	}
+	if link = strings.TrimSpace(link); link != "" {
+		return link
+	}
+	return "(untitled)"
+}
+
+// truncateWords shortens text to at most limit characters, breaking at a
+// word boundary and marking the cut with an ellipsis.
+func truncateWords(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
 	}
+	cut := string(runes[:limit])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,.;:") + "…"
 }
 
-var cache = &Cache{}
+// updated returns when the item was last edited, from <atom:updated> or an
+// item-level <lastBuildDate>, or "" when the feed doesn't say.
+func (i Item) updated() string {
+	if i.Updated != "" {
+		return i.Updated
+	}
+	return i.BuildDate
+}
 
-func fetchRSS(url string) (*RSS, error) {
-	log.Printf("Fetching RSS from: %s", url)
+// published returns the item's publication date, preferring pubDate over
+// Dublin Core dc:date.
+func (i Item) published() string {
+	if i.PubDate != "" {
+		return i.PubDate
+	}
+	return i.DCDate
+}
+
+// FetchResult is the outcome of a conditional feed fetch. RSS is nil when the
+// upstream responded 304 Not Modified.
+type FetchResult struct {
+	RSS         *RSS
+	Validators  Validators
+	NotModified bool
+	// URL is the feed's own URL, which is not the one fetched when the feed
+	// was discovered on an HTML page.
+	URL string
+}
+
+func fetchRSS(ctx context.Context, url string) (*RSS, error) {
+	result, err := fetchFeed(ctx, url, Validators{})
+	if err != nil {
+		return nil, err
+	}
+	return result.RSS, nil
+}
+
+// fetchFeed fetches and parses a feed, sending If-None-Match and
+// If-Modified-Since when validators from a previous fetch are given.
+func fetchFeed(ctx context.Context, url string, validators Validators) (result *FetchResult, err error) {
+	ctx, span := tracer.Start(ctx, "fetch feed", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(feedHostAttribute(url)))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "fetch failed")
+		} else {
+			span.SetAttributes(attribute.Bool("feed.not_modified", result.NotModified))
+			if result.RSS != nil {
+				span.SetAttributes(attribute.Int("feed.items", len(result.RSS.Channel.Items)))
+			}
+		}
+		span.End()
+	}()
+
+	release, err := acquireFetchSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	logger := loggerFrom(ctx).With("url", redactURL(url))
+	logger.Info("fetching feed")
+
+	start := time.Now()
+	defer func() {
+		fetchDuration.Observe(time.Since(start).Seconds())
+		if err != nil {
+			fetchErrors.Inc()
+		}
+	}()
+
+	for attempt := 0; ; attempt++ {
+		result, err = fetchFeedOnce(ctx, url, validators)
+
+		var retryable *retryableError
+		if !errors.As(err, &retryable) || attempt >= fetchRetries || ctx.Err() != nil {
+			return result, err
+		}
+
+		delay := retryDelay(attempt, retryable.retryAfter)
+		logger.Warn("retrying feed fetch", "delay", delay, "error", err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to fetch RSS: %w", ctx.Err())
+		}
+	}
+}
+
+// fetchFeedOnce makes a single fetch attempt. Failures worth retrying are
+// returned as a *retryableError.
+func fetchFeedOnce(ctx context.Context, url string, validators Validators) (*FetchResult, error) {
+	// The deadline covers reading the body too
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
 
 	// Create request with proper headers
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	// Add headers to mimic a real browser
-	req.Header.Set("User-Agent", "RSS2ICal/1.0 (Go HTTP Client)")
-	req.Header.Set("Accept", "application/rss+xml, application/xml, text/xml, */*")
+	setFetchHeaders(req)
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	// Credentials in the URL are sent as a header rather than in the
+	// request line, which some servers reject
+	if user := req.URL.User; user != nil {
+		password, _ := user.Password()
+		req.SetBasicAuth(user.Username(), password)
+		req.URL.User = nil
+	}
+
+	// Headers the client asked to forward, already checked against the
+	// allowlist
+	for name, values := range upstreamHeaders(ctx) {
+		req.Header[name] = values
+	}
+
+	// With discovery the validators may belong to a feed this page links
+	// to, so they are kept for that feed's fetch
+	pageValidators := validators
+	if feedDiscovery(ctx) {
+		pageValidators = Validators{}
+	}
+	conditional := pageValidators.ETag != "" || pageValidators.LastModified != ""
+	if pageValidators.ETag != "" {
+		req.Header.Set("If-None-Match", pageValidators.ETag)
+	}
+	if pageValidators.LastModified != "" {
+		req.Header.Set("If-Modified-Since", pageValidators.LastModified)
+	}
+
+	resp, err := fetchClient.Do(req)
 	if err != nil {
-		log.Printf("HTTP GET error: %v", err)
-		return nil, fmt.Errorf("failed to fetch RSS: %w", err)
+		err = fmt.Errorf("failed to fetch RSS: %w", err)
+		if transientNetworkError(err) {
+			return nil, &retryableError{err: err}
+		}
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	log.Printf("RSS fetch status: %d", resp.StatusCode)
+	loggerFrom(ctx).Info("feed fetched", "url", redactURL(url), "status", resp.StatusCode)
+	if resp.StatusCode == http.StatusNotModified && conditional {
+		return &FetchResult{Validators: validators, NotModified: true, URL: url}, nil
+	}
+	if resp.StatusCode >= 500 {
+		return nil, &retryableError{
+			err:        fmt.Errorf("RSS fetch returned status: %d", resp.StatusCode),
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("RSS fetch returned status: %d", resp.StatusCode)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	var reader io.Reader = resp.Body
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress RSS body: %w", err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	// Read one byte past the limit to tell a feed that fits exactly from one
+	// that is too large. The limit applies after decompression.
+	body, err := io.ReadAll(io.LimitReader(reader, int64(maxFeedBytes)+1))
 	if err != nil {
 		return nil, fmt.Errorf("failed to read RSS body: %w", err)
 	}
+	if len(body) > maxFeedBytes {
+		return nil, fmt.Errorf("RSS feed exceeds the %d byte limit", maxFeedBytes)
+	}
+
+	if feedDiscovery(ctx) && isHTMLPage(body) {
+		linkURL, ok := discoverFeedURL(body, resp.Request.URL)
+		if !ok {
+			return nil, errors.New("no RSS or Atom feed link found on HTML page")
+		}
+		// The page's link gets the same checks as a URL given by the client
+		feedURL, err := normalizeFeedURL(linkURL)
+		if err != nil {
+			return nil, fmt.Errorf("discovered feed on %s: %w", redactURL(url), err)
+		}
+		if !hostAllowed(feedURL) {
+			return nil, fmt.Errorf("discovered feed on %s: feed host not allowed", redactURL(feedURL))
+		}
+		loggerFrom(ctx).Info("discovered feed", "page", redactURL(url), "feed", redactURL(feedURL))
+		return fetchFeedOnce(withFeedDiscovery(ctx, false), feedURL, validators)
+	}
+
+	rss, err := parseRSS(body)
+	if err != nil {
+		return nil, err
+	}
+	rss.resolveLinks(resp.Request.URL)
+	if dropped := rss.Channel.truncateItems(maxItems); dropped > 0 {
+		loggerFrom(ctx).Warn("feed has too many items, truncating",
+			"url", redactURL(url), "items", maxItems+dropped, "max_items", maxItems)
+	}
+
+	return &FetchResult{
+		RSS: rss,
+		Validators: Validators{
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		},
+		URL: url,
+	}, nil
+}
 
-	var rss RSS
-	if err := xml.Unmarshal(body, &rss); err != nil {
+// version is the release this binary was built from, set at build time
+// with -ldflags "-X main.version=...".
+var version = "dev"
+
+// userAgent identifies us to upstream servers. It is USER_AGENT when set.
+var userAgent = defaultUserAgent()
+
+func defaultUserAgent() string {
+	return "RSS2ICal/" + version + " (Go HTTP Client)"
+}
+
+// setFetchHeaders adds the headers sent with every upstream feed request.
+func setFetchHeaders(req *http.Request) {
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/rss+xml, application/xml, text/xml, */*")
+	// Setting this ourselves disables net/http's transparent decompression,
+	// so the body is gunzipped below when the server honors it
+	req.Header.Set("Accept-Encoding", "gzip")
+}
+
+// checkRedirect stops after maxRedirects hops and keeps our request headers
+// on each hop, including redirects to other hosts. Headers forwarded for the
+// client, such as API keys, only go to the feed's own host: net/http strips
+// Authorization and Cookie on such redirects, but not these.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	if !hostAllowed(req.URL.String()) {
+		return fmt.Errorf("redirect to %s: feed host not allowed", req.URL.Host)
+	}
+	setFetchHeaders(req)
+	if req.URL.Host != via[0].URL.Host {
+		for name := range upstreamHeaders(req.Context()) {
+			req.Header.Del(name)
+		}
+	}
+	return nil
+}
+
+// parseRSS detects the feed format from the document's root element and
+// unmarshals it into the common RSS representation.
+func parseRSS(data []byte) (*RSS, error) {
+	root, err := feedRoot(data)
+	if err != nil {
 		return nil, fmt.Errorf("failed to parse RSS: %w", err)
 	}
 
-	return &rss, nil
+	var rss *RSS
+	switch root.Local {
+	case "rss":
+		rss = &RSS{}
+		if err := newFeedDecoder(data).Decode(rss); err != nil {
+			return nil, fmt.Errorf("failed to parse RSS: %w", err)
+		}
+	case "feed":
+		var atom Atom
+		if err := newFeedDecoder(data).Decode(&atom); err != nil {
+			return nil, fmt.Errorf("failed to parse Atom: %w", err)
+		}
+		rss = atom.toRSS()
+	case "RDF":
+		var rdf RDF
+		if err := newFeedDecoder(data).Decode(&rdf); err != nil {
+			return nil, fmt.Errorf("failed to parse RSS 1.0: %w", err)
+		}
+		rss = rdf.toRSS()
+	default:
+		return nil, fmt.Errorf("unsupported feed format: <%s>", root.Local)
+	}
+	rss.normalize()
+	return rss, nil
+}
+
+// normalize tidies text as feeds commonly write it: titles become a single
+// line of plain text with entities decoded, whatever the html option, and
+// bodies lose the whitespace around them, typically left by CDATA sections,
+// but are otherwise kept verbatim.
+func (r *RSS) normalize() {
+	r.Channel.Title = cleanTitle(r.Channel.Title)
+	for i := range r.Channel.Items {
+		item := &r.Channel.Items[i]
+		item.Title = cleanTitle(item.Title)
+		item.Description = strings.TrimSpace(item.Description)
+		item.ContentEncoded = strings.TrimSpace(item.ContentEncoded)
+	}
+	r.resolveLinks(nil)
+}
+
+// newFeedDecoder returns a decoder for a feed document that converts
+// encodings such as ISO-8859-1 or Windows-1252, as named by the XML
+// declaration, to UTF-8.
+func newFeedDecoder(data []byte) *xml.Decoder {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = charset.NewReaderLabel
+	return decoder
+}
+
+// feedRoot returns the name of the document's root element.
+func feedRoot(data []byte) (xml.Name, error) {
+	decoder := newFeedDecoder(data)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return xml.Name{}, err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name, nil
+		}
+	}
+}
+
+// parseTime parses an RSS date, reading dates without an offset as UTC.
+func parseTime(pubDate string) (time.Time, error) {
+	return parseTimeIn(pubDate, time.UTC)
+}
+
+// dateFormats are the feed date layouts parseTimeIn tries, in order.
+var dateFormats = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 02 Jan 2006 15:04:05 -0700",
+	time.RFC3339,
+	// Without seconds
+	"Mon, 2 Jan 2006 15:04 -0700",
+	"Mon, 2 Jan 2006 15:04 MST",
+	// RFC 822 allows two-digit years, still seen in legacy feeds
+	"Mon, 2 Jan 06 15:04:05 -0700",
+	"Mon, 2 Jan 06 15:04:05 MST",
+	"Mon, 2 Jan 06 15:04 -0700",
+	"Mon, 2 Jan 06 15:04 MST",
+	time.RFC822Z,
+	time.RFC822,
+	// Without a weekday
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04 -0700",
+	// Formats without an offset
+	"Mon, 02 Jan 2006 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+}
+
+// parseTimeIn parses an RSS date, interpreting dates that carry no offset
+// in the given location. It returns an error when pubDate matches no known
+// format, leaving the fallback to the caller.
+func parseTimeIn(pubDate string, loc *time.Location) (time.Time, error) {
+	if t, ok := parseDateFormats(pubDate, loc); ok {
+		return t, nil
+	}
+	// Feeds in other languages name weekdays and months in them
+	if english, ok := englishDate(pubDate); ok {
+		if t, ok := parseDateFormats(english, loc); ok {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date %q", pubDate)
+}
+
+// parseDateFormats parses date with the first of dateFormats it matches.
+func parseDateFormats(date string, loc *time.Location) (time.Time, bool) {
+	for _, format := range dateFormats {
+		if t, err := time.ParseInLocation(format, date, loc); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// defaultProductID is the PRODID of calendars without a prodid override.
+const defaultProductID = "-//RSS2ICal//EN"
+
+// defaultCalendarName is the NAME of calendars whose feed has no title, since
+// some clients reject a subscription without one.
+const defaultCalendarName = "Untitled Feed"
+
+func rssToICal(rss *RSS, opts CalendarOptions) (string, error) {
+	cal, err := buildCalendar(rss, opts)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if err := serializeCalendar(cal, &b); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// serializeCalendar writes cal to w as it is serialized, rather than
+// building the whole document in memory first.
+func serializeCalendar(cal *ics.Calendar, w io.Writer) error {
+	return cal.SerializeTo(listSeparatorWriter{w: w})
+}
+
+// unfoldLines joins folded continuation lines of a serialized calendar and
+// ends every line with LF instead of CRLF. Continuations are the only lines
+// starting with a space, so no property is merged with the next.
+func unfoldLines(ical string) string {
+	var b strings.Builder
+	unfolded := &unfoldWriter{w: &b}
+	io.WriteString(unfolded, ical)
+	unfolded.Flush()
+	return b.String()
 }
 
-func parseTime(pubDate string) time.Time {
-	// Try common RSS date formats
-	formats := []string{
-		time.RFC1123Z,
-		time.RFC1123,
-		"Mon, 2 Jan 2006 15:04:05 -0700",
-		"Mon, 02 Jan 2006 15:04:05 -0700",
-		time.RFC3339,
+// unfoldWriter does unfoldLines on a calendar as it is written. A line
+// break split across writes is held back until the next byte shows whether
+// it folds the line; Flush writes one left at the end.
+type unfoldWriter struct {
+	w io.Writer
+	// pending is how much of a "\r\n" was seen last: 0, 1 or 2 bytes
+	pending int
+	out     []byte
+}
+
+func (u *unfoldWriter) Write(p []byte) (int, error) {
+	u.out = u.out[:0]
+	for _, c := range p {
+		switch u.pending {
+		case 1:
+			u.pending = 0
+			if c == '\n' {
+				u.pending = 2
+				continue
+			}
+			u.out = append(u.out, '\r')
+		case 2:
+			u.pending = 0
+			if c == ' ' {
+				continue
+			}
+			u.out = append(u.out, '\n')
+		}
+		if c == '\r' {
+			u.pending = 1
+			continue
+		}
+		u.out = append(u.out, c)
+	}
+	if _, err := u.w.Write(u.out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes a line break held back at the end of the calendar.
+func (u *unfoldWriter) Flush() error {
+	var rest string
+	switch u.pending {
+	case 1:
+		rest = "\r"
+	case 2:
+		rest = "\n"
 	}
+	u.pending = 0
+	_, err := io.WriteString(u.w, rest)
+	return err
+}
 
-	for _, format := range formats {
-		if t, err := time.Parse(format, pubDate); err == nil {
-			return t
+// listSeparatorWriter replaces listSeparator with a comma on the way
+// through, completing multi-valued properties such as CATEGORIES.
+type listSeparatorWriter struct {
+	w io.Writer
+}
+
+func (l listSeparatorWriter) Write(p []byte) (int, error) {
+	if bytes.IndexByte(p, listSeparator[0]) < 0 {
+		return l.w.Write(p)
+	}
+	if _, err := l.w.Write(bytes.ReplaceAll(p, []byte(listSeparator), []byte(","))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// buildCalendar converts a feed into a calendar with one event per item.
+func buildCalendar(rss *RSS, opts CalendarOptions) (*ics.Calendar, error) {
+	cal, _, _, err := buildVersionedCalendar(rss, opts, nil)
+	return cal, err
+}
+
+// buildVersionedCalendar is buildCalendar for a feed converted before:
+// events whose content changed since previous get the next SEQUENCE. It also
+// returns the versions of the events it built and how many items were
+// skipped because their event couldn't be built.
+func buildVersionedCalendar(rss *RSS, opts CalendarOptions, previous eventVersions) (*ics.Calendar, eventVersions, int, error) {
+	cal := newCalendar(rss.Channel, opts)
+
+	now := time.Now()
+	items := calendarItems(rss, opts)
+	versions := make(eventVersions, len(items))
+	skipped := 0
+	for _, item := range items {
+		version := previous.next(item.eventUID, item.contentHash(opts), now)
+		// One bad item shouldn't cost the rest of the calendar
+		if err := addEvent(cal, item, version, opts); err != nil {
+			slog.Warn("skipping item that could not be converted", "uid", item.eventUID, "error", err)
+			skipped++
+			continue
 		}
+		versions[item.eventUID] = version
 	}
 
-	// Fallback to current time if parsing fails
-	return time.Now()
+	return cal, versions, skipped, nil
 }
 
-func rssToICal(rss *RSS) (string, error) {
+// newCalendar returns an empty calendar with the channel's name,
+// description and image, and a refresh interval matching its cache lifetime.
+func newCalendar(channel Channel, opts CalendarOptions) *ics.Calendar {
 	cal := ics.NewCalendar()
-	cal.SetMethod(ics.MethodPublish)
-	cal.SetProductId("-//RSS2ICal//EN")
-	cal.SetName(rss.Channel.Title)
-	cal.SetDescription(rss.Channel.Description)
+	if opts.Method != "" {
+		cal.SetMethod(opts.Method)
+	}
+	productID := defaultProductID
+	if opts.ProductID != "" {
+		productID = opts.ProductID
+	}
+	cal.SetProductId(productID)
+	name := channel.Title
+	if opts.Name != "" {
+		name = opts.Name
+	}
+	if name == "" {
+		name = defaultCalendarName
+	}
+	cal.SetName(name)
+	cal.SetDescription(channel.Description)
+	if opts.Location != nil {
+		cal.SetXWRTimezone(opts.Location.String())
+	}
+
+	// Ask clients to re-poll as often as the calendar is cached
+	refresh := channel.declaredTTL()
+	if refresh <= 0 {
+		refresh = cacheTTL
+	}
+	cal.SetXPublishedTTL(icalDuration(refresh))
+	cal.SetRefreshInterval(icalDuration(refresh))
+
+	if image := strings.TrimSpace(channel.Image.URL); image != "" {
+		// RFC 7986 IMAGE; VALUE=URI keeps the URL from being escaped as text
+		cal.CalendarProperties = append(cal.CalendarProperties, ics.CalendarProperty{
+			BaseProperty: ics.BaseProperty{
+				IANAToken:      "IMAGE",
+				ICalParameters: map[string][]string{string(ics.ParameterValue): {string(ics.ValueDataTypeUri)}},
+				Value:          image,
+			},
+		})
+	}
+	return cal
+}
+
+// addEvent adds item's event to cal. An item whose data can't be written,
+// or whose event panics while being built, returns an error and leaves cal
+// as it was.
+func addEvent(cal *ics.Calendar, item scheduledItem, version eventVersion, opts CalendarOptions) (err error) {
+	components := len(cal.Components)
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic building event: %v", r)
+		}
+		if err != nil {
+			cal.Components = cal.Components[:components]
+		}
+	}()
+
+	event := cal.AddEvent(item.eventUID)
+	event.SetDtStampTime(version.stamp)
+	event.SetSequence(version.sequence)
+	event.SetSummary(item.Title)
+	event.SetDescription(item.eventDescription)
+	if body := item.body(opts); !opts.RawHTML && containsMarkup(body) {
+		// Clients that render HTML, such as Outlook, use this instead
+		event.SetProperty(ics.ComponentProperty("X-ALT-DESC"), body, ics.WithFmtType("text/html"))
+	}
+	if err := checkURIValue(item.Link); err != nil {
+		return fmt.Errorf("invalid link %q: %w", item.Link, err)
+	}
+	event.SetURL(item.Link)
+	setOrganizer(event, item.eventOrganizer)
+	if contact := item.contact(); contact != "" {
+		event.AddProperty(ics.ComponentProperty("CONTACT"), contact)
+	}
+	for _, name := range item.podcastPersons() {
+		if name != item.contact() {
+			event.AddProperty(ics.ComponentProperty("CONTACT"), name)
+		}
+	}
+	if opts.Status != "" {
+		event.SetStatus(opts.Status)
+	}
+	if opts.Busy {
+		event.SetTimeTransparency(ics.TransparencyOpaque)
+	} else {
+		event.SetTimeTransparency(ics.TransparencyTransparent)
+	}
+	if location := item.location(opts.LocationField); location != "" {
+		event.SetLocation(location)
+	}
+	if lat, lon, ok := item.geo(); ok {
+		event.SetGeo(formatCoordinate(lat), formatCoordinate(lon))
+	}
+	if categories := item.categories(); len(categories) > 0 {
+		event.SetProperty(ics.ComponentPropertyCategories, strings.Join(categories, listSeparator))
+	}
+	if image := item.image(); image != "" {
+		if err := checkURIValue(image); err != nil {
+			return fmt.Errorf("invalid image URL %q: %w", image, err)
+		}
+		event.SetProperty(ics.ComponentProperty("IMAGE"), image, ics.WithValue(string(ics.ValueDataTypeUri)))
+	}
+	for _, enclosure := range item.Enclosures {
+		if enclosure.URL == "" {
+			continue
+		}
+		if err := checkURIValue(enclosure.URL); err != nil {
+			return fmt.Errorf("invalid enclosure URL %q: %w", enclosure.URL, err)
+		}
+		if enclosure.Type != "" {
+			event.AddAttachmentURL(enclosure.URL, enclosure.Type)
+		} else {
+			event.AddAttachment(enclosure.URL)
+		}
+	}
+
+	if opts.AllDay {
+		event.SetAllDayStartAt(item.startTime)
+		event.SetAllDayEndAt(item.endTime)
+	} else if opts.Location != nil {
+		tzid := &ics.KeyValues{Key: string(ics.ParameterTzid), Value: []string{opts.Location.String()}}
+		event.SetProperty(ics.ComponentPropertyDtStart, item.startTime.Format(icalLocalTimeFormat), tzid)
+		event.SetProperty(ics.ComponentPropertyDtEnd, item.endTime.Format(icalLocalTimeFormat), tzid)
+	} else {
+		event.SetStartAt(item.startTime)
+		event.SetEndAt(item.endTime)
+	}
+
+	event.SetCreatedTime(item.pubTime)
+	event.SetModifiedAt(item.modTime)
+
+	if opts.Alarm > 0 {
+		alarm := event.AddAlarm()
+		alarm.SetAction(ics.ActionDisplay)
+		alarm.SetProperty(ics.ComponentPropertyDescription, item.Title)
+		alarm.SetTrigger("-" + icalDuration(opts.Alarm))
+	}
+	return nil
+}
+
+// checkURIValue rejects control characters in a value written as a URI.
+// Unlike text, URIs aren't escaped, so a line break would end the property
+// and start another.
+func checkURIValue(value string) error {
+	if strings.IndexFunc(value, unicode.IsControl) >= 0 {
+		return errors.New("control characters are not allowed")
+	}
+	return nil
+}
+
+// icalDuration formats d as an RFC 5545 DURATION such as PT1H30M.
+func icalDuration(d time.Duration) string {
+	var b strings.Builder
+	b.WriteString("PT")
+	if hours := d / time.Hour; hours > 0 {
+		fmt.Fprintf(&b, "%dH", hours)
+	}
+	if minutes := d % time.Hour / time.Minute; minutes > 0 {
+		fmt.Fprintf(&b, "%dM", minutes)
+	}
+	if seconds := d % time.Minute / time.Second; seconds > 0 || b.Len() == 2 {
+		fmt.Fprintf(&b, "%dS", seconds)
+	}
+	return b.String()
+}
+
+// scheduledItem is a feed item paired with the times its event is built from.
+type scheduledItem struct {
+	Item
+	pubTime time.Time
+	// modTime is when the item was last edited, pubTime unless the feed
+	// gives a later update time
+	modTime   time.Time
+	startTime time.Time
+	endTime   time.Time
+	// dated is false when no date could be parsed for the item, in which
+	// case startTime is the conversion time
+	dated bool
+
+	// eventUID and eventDescription are the event's UID and DESCRIPTION,
+	// set by calendarItems
+	eventUID         string
+	eventDescription string
+	// eventOrganizer is the event's ORGANIZER, set by calendarItems
+	eventOrganizer person
+}
+
+// calendarItems selects, orders and prepares the items that become events,
+// shared by every output format so they stay consistent.
+func calendarItems(rss *RSS, opts CalendarOptions) []scheduledItem {
+	items := withinRange(scheduleItems(filterItems(rss.Channel.Items, opts), opts), opts)
+	if opts.Limit > 0 && len(items) > opts.Limit {
+		// Keep the most recent items
+		sortItems(items, true)
+		items = items[:opts.Limit]
+	}
+	sortItems(items, opts.SortDescending)
+	if opts.Spread > 0 && !opts.AllDay {
+		spreadItems(items, opts.Spread)
+	}
+
+	// Feeds sometimes reuse a GUID across items; later duplicates get a
+	// numbered suffix so they don't collapse into a single event
+	uids := make(map[string]int)
+	for i := range items {
+		item := &items[i]
+		item.eventUID = item.uid()
+		if uids[item.eventUID]++; uids[item.eventUID] > 1 {
+			item.eventUID = fmt.Sprintf("%s-%d", item.eventUID, uids[item.eventUID])
+		}
+		item.eventUID = opts.UIDPrefix + item.eventUID
+		item.eventOrganizer = organizer(item.Item, rss.Channel)
 
-	for _, item := range rss.Channel.Items {
-		event := cal.AddEvent(item.GUID)
-		event.SetSummary(item.Title)
-		event.SetDescription(item.Description)
-		event.SetURL(item.Link)
+		item.eventDescription = item.body(opts)
+		if !opts.RawHTML {
+			item.eventDescription = htmlToText(item.eventDescription)
+		}
 
-		startTime := parseTime(item.PubDate)
-		event.SetStartAt(startTime)
-		event.SetEndAt(startTime.Add(time.Hour)) // Default 1-hour duration
+		// Untitled items, common in microblog feeds, would otherwise show
+		// as blank events
+		if strings.TrimSpace(item.Title) == "" {
+			item.Title = fallbackTitle(htmlToText(item.body(opts)), item.Link)
+		}
+	}
+	return items
+}
 
-		event.SetCreatedTime(startTime)
-		event.SetModifiedAt(startTime)
+// sortItems orders items by start time, keeping feed order for ties.
+// Undated items sort last in either direction.
+func sortItems(items []scheduledItem, descending bool) {
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if a.dated != b.dated {
+			return a.dated
+		}
+		if descending {
+			return a.startTime.After(b.startTime)
+		}
+		return a.startTime.Before(b.startTime)
+	})
+}
+
+// spreadItems staggers items that start at the same time, so a batch
+// published at once doesn't stack up: the second moves spread later, the
+// third twice that and so on, in the order given. Undated items are left
+// alone.
+func spreadItems(items []scheduledItem, spread time.Duration) {
+	shared := make(map[int64]int)
+	for i := range items {
+		item := &items[i]
+		if !item.dated {
+			continue
+		}
+		key := item.startTime.UnixNano()
+		offset := time.Duration(shared[key]) * spread
+		shared[key]++
+		item.startTime = item.startTime.Add(offset)
+		item.endTime = item.endTime.Add(offset)
 	}
+}
 
-	return cal.Serialize(), nil
+// scheduleItems resolves when each item's event starts according to opts.
+func scheduleItems(items []Item, opts CalendarOptions) []scheduledItem {
+	scheduled := make([]scheduledItem, 0, len(items))
+	var unparsed []string
+	for _, item := range items {
+		pubTime, err := parseTimeIn(item.published(), opts.location())
+		dated := err == nil
+		if !dated {
+			// Undated items are placed at the conversion time and sort last
+			pubTime = time.Now()
+			if item.published() != "" {
+				unparsed = append(unparsed, item.published())
+			}
+		}
+		modTime := pubTime
+		if updated, err := parseTimeIn(item.updated(), opts.location()); err == nil && dated && updated.After(pubTime) {
+			modTime = updated
+		}
+
+		startTime := pubTime
+		if opts.DateFromContent {
+			if contentTime, ok := extractContentDate(htmlToText(item.body(opts)), opts.location()); ok {
+				startTime = contentTime
+				dated = true
+			}
+		}
+		if opts.Location != nil {
+			startTime = startTime.In(opts.Location)
+		}
+		if opts.FixedTime && dated {
+			year, month, day := startTime.Date()
+			startTime = time.Date(year, month, day, int(opts.At/time.Hour), int(opts.At%time.Hour/time.Minute), 0, 0, startTime.Location())
+		}
+
+		endTime := startTime.Add(item.duration(opts))
+		if opts.AllDay {
+			// All-day events end on the following day (DTEND is exclusive)
+			year, month, day := startTime.Date()
+			startTime = time.Date(year, month, day, 0, 0, 0, 0, startTime.Location())
+			endTime = startTime.AddDate(0, 0, 1)
+		}
+
+		scheduled = append(scheduled, scheduledItem{
+			Item:      item,
+			pubTime:   pubTime,
+			modTime:   modTime,
+			startTime: startTime,
+			endTime:   endTime,
+			dated:     dated,
+		})
+	}
+
+	// One warning per feed, so a feed of bad dates doesn't flood the log
+	if len(unparsed) > 0 {
+		slog.Warn("unrecognized item dates, using the conversion time",
+			"items", len(unparsed),
+			"example", unparsed[0])
+	}
+	return scheduled
 }
 
 func calendarHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+	start := time.Now()
+	id := requestID(r)
+	r, span := startRequestSpan(r, "calendar")
+	logger := loggerFrom(r.Context()).With("request_id", id)
+	r = r.WithContext(withLogger(r.Context(), logger))
+	w.Header().Set("X-Request-ID", id)
+
+	var feeds []string
+	var cacheHit bool
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	defer func() {
+		span.SetAttributes(
+			attribute.Int("http.status_code", recorder.status),
+			attribute.Bool("cache.hit", cacheHit))
+		if recorder.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(recorder.status))
+		}
+		span.End()
+		requestsTotal.WithLabelValues(strconv.Itoa(recorder.status)).Inc()
+		logger.Info("calendar request",
+			"url", strings.Join(feeds, ","),
+			"client_ip", clientIP(r),
+			"status", recorder.status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"cache_hit", cacheHit)
+	}()
+	w = recorder
+
+	w.Header().Set("Access-Control-Allow-Origin", corsOrigin)
+
+	// CORS preflight from browser-based clients
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Get RSS URL from query parameter
-	rssURL := r.URL.Query().Get("url")
-	if rssURL == "" {
+	// Get RSS URLs from query parameters
+	query, err := calendarQuery(r.URL.RawQuery)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	urls := feedURLs(query["url"])
+	if len(urls) == 0 {
 		http.Error(w, "RSS URL required: use ?url=... parameter", http.StatusBadRequest)
 		return
 	}
+	for i, rssURL := range urls {
+		normalized, err := normalizeFeedURL(rssURL)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		urls[i] = normalized
+		feeds = append(feeds, redactURL(normalized))
+		if !hostAllowed(normalized) {
+			http.Error(w, "Feed host not allowed", http.StatusForbidden)
+			return
+		}
+	}
 
-	// Check cache first
-	if cached, ok := cache.Get(rssURL); ok {
-		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
-		w.Header().Set("Cache-Control", "public, max-age=300")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(cached))
+	applyFeedDefaults(query, urls)
+
+	// Clients asking for JSON get it without a format parameter
+	if query.Get("format") == "" && acceptsJSON(r) {
+		query.Set("format", formatJSON)
+	}
+
+	opts, err := parseCalendarOptions(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Fetch fresh data
-	rss, err := fetchRSS(rssURL)
+	headers, err := parseUpstreamHeaders(query["header"])
 	if err != nil {
-		log.Printf("Error fetching RSS from %s: %v", rssURL, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r = r.WithContext(withUpstreamHeaders(r.Context(), headers))
+	if opts.Discover {
+		r = r.WithContext(withFeedDiscovery(r.Context(), true))
+	}
+
+	// Check cache first, keyed by the normalized URLs
+	query["url"] = urls
+	key := cacheKey(query)
+	if cached, ok := cache.Fresh(key); ok {
+		cacheHits.Inc()
+		cacheHit = true
+		writeCachedCalendar(w, r, opts.contentType(), cached)
+		return
+	}
+	cacheMisses.Inc()
+
+	if len(urls) > 1 {
+		serveMergedCalendar(w, r, key, urls, opts)
+		return
+	}
+	rssURL := urls[0]
+
+	if failures.Failed(rssURL) {
+		if opts.ErrorsInline {
+			writeFeedError(w, r, opts, errRecentFailure)
+			return
+		}
+		http.Error(w, "RSS feed recently failed to fetch, try again later", http.StatusBadGateway)
+		return
+	}
+
+	// Fetch fresh data, revalidating a stale entry if we have one
+	stale, hasStale := cache.Lookup(key)
+	// Concurrent requests for the same feed share one fetch, and a request
+	// stops waiting if its client disconnects
+	result, err := fetchFeedShared(r.Context(), rssURL, stale.validators)
+	if errors.Is(err, errPrivateAddress) {
+		logger.Warn("refusing to fetch feed", "url", redactURL(rssURL), "error", err)
+		http.Error(w, "Feed host not allowed", http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, errFetchBusy) {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Server busy, try again later", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil && fetchAbandoned(r.Context(), err) {
+		// The client is gone, so there's no one to answer
+		return
+	}
+	if err != nil {
+		logger.Error("feed fetch failed", "url", redactURL(rssURL), "error", err)
+		failures.Remember(rssURL)
+		if opts.ErrorsInline {
+			writeFeedError(w, r, opts, err)
+			return
+		}
 		http.Error(w, "Failed to fetch RSS feed", http.StatusInternalServerError)
 		return
 	}
+	failures.Forget(rssURL)
+
+	if result.NotModified && hasStale {
+		cache.Touch(key)
+		writeCachedCalendar(w, r, opts.contentType(), stale)
+		return
+	}
+	rss := result.RSS
+	if opts.MaxPages > 1 {
+		rss = withNextPages(r.Context(), rss, result.URL, opts.MaxPages)
+	}
 
-	ical, err := rssToICal(rss)
+	conv, err := prepareCalendar(r.Context(), rss, opts, stale.versions)
 	if err != nil {
-		log.Printf("Error converting to iCal: %v", err)
-		http.Error(w, "Failed to convert to iCalendar", http.StatusInternalServerError)
+		logger.Error("calendar conversion failed", "url", redactURL(rssURL), "format", opts.Format, "error", err)
+		if opts.Format == formatJSON {
+			http.Error(w, "Failed to convert to JSON", http.StatusInternalServerError)
+		} else {
+			http.Error(w, "Failed to convert to iCalendar", http.StatusInternalServerError)
+		}
 		return
 	}
 
+	entry := conv.entry
+	entry.validators = result.Validators
+	entry.ttl = rss.Channel.declaredTTL()
+	entry, err = writeConversion(w, r, opts.contentType(), conv, entry)
+	if err != nil {
+		logger.Error("calendar write failed", "url", redactURL(rssURL), "error", err)
+		return
+	}
 	// Cache the result
-	cache.Set(rssURL, ical)
+	cache.Store(key, entry)
+}
 
-	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
-	w.Header().Set("Cache-Control", "public, max-age=300")
+// writeConversion sends a freshly converted calendar, returning entry with
+// the data and ETag for the cache. The calendar is streamed to the client
+// while a copy is kept and hashed, so it is never held twice; the ETag is
+// only known once the body is sent, so it comes with later cache hits. HEAD
+// requests send no body, so theirs is buffered first to send the ETag too.
+// An error is only returned before anything is written; a streamed body that
+// fails aborts the handler instead, so nothing is cached.
+func writeConversion(w http.ResponseWriter, r *http.Request, contentType string, conv *conversion, entry CacheEntry) (CacheEntry, error) {
+	entry.generated = time.Now()
+	var data strings.Builder
+	if r.Method == http.MethodHead {
+		if err := conv.writeTo(&data); err != nil {
+			return entry, err
+		}
+		entry.data = data.String()
+		entry.etag = calendarETag(entry.data)
+		writeCachedCalendar(w, r, contentType, entry)
+		return entry, nil
+	}
+
+	setCountHeaders(w, entry)
+	setValidatorHeaders(w, entry)
+	hash := sha256.New()
+	streamCalendar(w, r, contentType, func(out io.Writer) error {
+		return conv.writeTo(io.MultiWriter(out, &data, hash))
+	})
+	entry.data = data.String()
+	entry.etag = `"` + hex.EncodeToString(hash.Sum(nil)) + `"`
+	return entry, nil
+}
+
+// writeCachedCalendar serves a cached calendar, answering 304 Not Modified
+// when the client's copy is still current.
+func writeCachedCalendar(w http.ResponseWriter, r *http.Request, contentType string, entry CacheEntry) {
+	setCountHeaders(w, entry)
+	if notModified(w, r, entry) {
+		return
+	}
+	writeCalendar(w, r, contentType, entry.data)
+}
+
+// setCountHeaders reports how many events the calendar has and how many
+// items were skipped.
+func setCountHeaders(w http.ResponseWriter, entry CacheEntry) {
+	w.Header().Set("X-Event-Count", strconv.Itoa(entry.events))
+	if entry.skipped > 0 {
+		w.Header().Set("X-Skipped-Items", strconv.Itoa(entry.skipped))
+	}
+}
+
+// calendarETag is a strong entity tag for calendar data.
+func calendarETag(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// setValidatorHeaders sets Last-Modified to when a calendar was generated
+// and ETag to its entity tag, when it has one.
+func setValidatorHeaders(w http.ResponseWriter, entry CacheEntry) {
+	w.Header().Set("Last-Modified", entry.generated.UTC().Format(http.TimeFormat))
+	if entry.etag != "" {
+		w.Header().Set("ETag", entry.etag)
+	}
+}
+
+// notModified sets the validator headers and, if the request's conditional
+// headers show the client already has entry, writes a 304 and returns true.
+// If-None-Match takes precedence over If-Modified-Since.
+func notModified(w http.ResponseWriter, r *http.Request, entry CacheEntry) bool {
+	setValidatorHeaders(w, entry)
+	setCacheHeaders(w)
+
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		if !etagMatches(match, entry.etag) {
+			return false
+		}
+	} else {
+		since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		// HTTP dates have whole-second precision
+		if err != nil || entry.generated.Truncate(time.Second).After(since) {
+			return false
+		}
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches reports whether an If-None-Match list names etag. GET uses
+// weak comparison, so a W/ prefix is ignored.
+func etagMatches(list, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeCalendar serves buffered calendar data of the given content type,
+// gzip-compressing it for clients that accept it. The cache always holds the
+// uncompressed form. Content-Length is only known up front for the
+// uncompressed body; compressed bodies are streamed without it.
+func writeCalendar(w http.ResponseWriter, r *http.Request, contentType, data string) {
+	if !acceptsGzip(r) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	}
+	streamCalendar(w, r, contentType, func(out io.Writer) error {
+		_, err := io.WriteString(out, data)
+		return err
+	})
+}
+
+// streamCalendar sends the calendar response headers and has write produce
+// the body, gzip-compressing it for clients that accept it. HEAD requests get
+// the same headers without the body. If the body can't be written in full,
+// the handler is aborted: the status is already sent, and ending the response
+// normally would hand the client a truncated calendar that looks complete.
+func streamCalendar(w http.ResponseWriter, r *http.Request, contentType string, write func(io.Writer) error) {
+	w.Header().Set("Content-Type", contentType)
+	setCacheHeaders(w)
+	gzipped := acceptsGzip(r)
+	if gzipped {
+		w.Header().Set("Content-Encoding", "gzip")
+	}
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(ical))
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	var err error
+	if gzipped {
+		// Closing writes the gzip trailer, so it's skipped after an error
+		gz := gzip.NewWriter(w)
+		if err = write(gz); err == nil {
+			err = gz.Close()
+		}
+	} else {
+		// The serializer writes a line at a time
+		buffered := bufio.NewWriter(w)
+		if err = write(buffered); err == nil {
+			err = buffered.Flush()
+		}
+	}
+	if err != nil {
+		loggerFrom(r.Context()).Error("calendar write failed", "error", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// setCacheHeaders sets the caching headers sent with calendars, including
+// 304 responses.
+func setCacheHeaders(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(cacheTTL.Seconds())))
+	w.Header().Set("Vary", "Accept, Accept-Encoding")
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding allows gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(encoding), ";")
+		if strings.TrimSpace(name) != "gzip" {
+			continue
+		}
+		// An explicit q=0 means "not acceptable"
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if weight, err := strconv.ParseFloat(q, 64); err == nil && weight == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
 }
 
 func main() {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = defaultPort
+	configPath := flag.String("config", "", "path to a YAML or JSON config file")
+	feedURL := flag.String("url", "", "convert this feed URL, file path or - for stdin once and exit instead of serving")
+	out := flag.String("out", "", "with -url, write the calendar to this file instead of stdout")
+	flag.Parse()
+
+	cfg, err := LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	cfg = cfg.withEnv()
+	if err := cfg.validate(); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+
+	// log.Printf output goes through the same handler
+	slog.SetDefault(newLogger(cfg.LogFormat, os.Stderr))
+
+	port := cfg.Port
+	cacheTTL = cfg.CacheTTL
+	cacheMaxEntries = cfg.CacheMaxEntries
+	if cfg.CacheBackend == cacheBackendRedis {
+		// Already checked by validate
+		backend, _ := newRedisCache(cfg.RedisURL)
+		ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+		if err := backend.Ping(ctx); err != nil {
+			slog.Warn("redis cache unreachable, calendars will be fetched upstream until it is", "error", err)
+		}
+		cancel()
+		cache = &Cache{backend: backend}
+	}
+	eventDuration = cfg.DefaultDuration
+	allowedHosts = cfg.AllowedHosts
+	allowPrivateNetworks = cfg.AllowPrivateNetworks
+	maxRedirects = cfg.MaxRedirects
+	negativeCacheTTL = cfg.NegativeCacheTTL
+	fetchRetries = cfg.FetchRetries
+	fetchTimeout = cfg.FetchTimeout
+	maxFeedBytes = cfg.MaxFeedBytes
+	maxItems = cfg.MaxItems
+	allowCookieHeader = cfg.AllowCookieHeader
+	feedDefaults = cfg.Feeds
+	if cfg.UserAgent != "" {
+		userAgent = cfg.UserAgent
+	}
+	fetchSlots = make(chan struct{}, cfg.MaxConcurrentFetches)
+	// Already checked by validate
+	trustedProxies, _ = parseTrustedProxies(cfg.TrustedProxies)
+	if cfg.RateLimit > 0 {
+		clientLimits = newRateLimiter(cfg.RateLimit)
+	}
+	if origin := os.Getenv("CORS_ORIGIN"); origin != "" {
+		corsOrigin = origin
+	}
+	healthcheckURL = os.Getenv("HEALTHCHECK_URL")
+	purgeToken = os.Getenv("PURGE_TOKEN")
+	debugEnabled, _ = strconv.ParseBool(os.Getenv("DEBUG_ENABLED"))
+
+	if *feedURL != "" {
+		if err := convertFeedTo(*feedURL, *out); err != nil {
+			log.Fatalf("Conversion failed: %v", err)
+		}
+		return
 	}
 
-	http.HandleFunc("/", homeHandler)
-	http.HandleFunc("/calendar", calendarHandler)
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+	server := newServer(":" + port)
+
+	slog.Info("starting RSS2ICal server",
+		"port", port,
+		"calendar", fmt.Sprintf("http://localhost:%s/calendar?url=<RSS_URL>", port),
+		"home", fmt.Sprintf("http://localhost:%s/", port),
+		"cache_backend", cfg.CacheBackend,
+		"cache_ttl", cacheTTL,
+		"cache_max_entries", cacheMaxEntries)
+
+	ln, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		log.Fatalf("Server failed to start: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := setupTracing(ctx)
+	if err != nil {
+		log.Fatalf("Tracing setup failed: %v", err)
+	}
+	defer func() {
+		// Flush spans from the last requests; ctx is already cancelled
+		flushCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		shutdownTracing(flushCtx)
+	}()
+
+	if err := serve(ctx, server, ln); err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
+}
+
+// newServer builds the HTTP server and its routes.
+func newServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", homeHandler)
+	mux.HandleFunc("/calendar", limitRate(calendarHandler))
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	})
+	mux.HandleFunc("/ready", readyHandler)
+	mux.HandleFunc("/cache/purge", purgeHandler)
+	mux.HandleFunc("/cache/stats", statsHandler)
+	mux.HandleFunc("/debug", limitRate(debugHandler))
+	mux.HandleFunc("/subscribe", subscribeHandler)
+	mux.HandleFunc("/batch", limitRate(batchHandler))
+	mux.Handle("/metrics", promhttp.Handler())
 
-	log.Printf("Starting RSS2ICal server on port %s", port)
-	log.Printf("Calendar endpoint: http://localhost:%s/calendar?url=<RSS_URL>", port)
-	log.Printf("Home page: http://localhost:%s/", port)
+	return &http.Server{Addr: addr, Handler: mux}
+}
 
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+// serve runs server on ln until ctx is cancelled, then shuts it down, giving
+// in-flight requests up to shutdownTimeout to complete.
+func serve(ctx context.Context, server *http.Server, ln net.Listener) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.Serve(ln)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
+
+// durationFromEnv reads a positive duration such as "10m" from the named
+// environment variable, falling back to def when it is unset or invalid.
+func durationFromEnv(name string, def time.Duration) time.Duration {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		log.Printf("Invalid %s %q, using default %v", name, raw, def)
+		return def
+	}
+	return d
+}
+
+// intFromEnv reads a positive integer from the named environment variable,
+// falling back to def when it is unset or invalid.
+func intFromEnv(name string, def int) int {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil || n <= 0 {
+		log.Printf("Invalid %s %q, using default %d", name, raw, def)
+		return def
+	}
+	return n
+}
+
+// nonNegativeIntFromEnv reads an integer from the named environment variable
+// like intFromEnv, but also accepts zero, for counts such as redirects where
+// zero turns the feature off.
+func nonNegativeIntFromEnv(name string, def int) int {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
 	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		log.Printf("Invalid %s %q, using default %d", name, raw, def)
+		return def
+	}
+	return n
 }
 
+// indexHTML is the landing page form, which builds /calendar and webcal://
+// links for a pasted feed URL.
+//
+//go:embed static/index.html
+var indexHTML []byte
+
 func homeHandler(w http.ResponseWriter, r *http.Request) {
+	// "/" matches every path the mux doesn't otherwise route
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	html := `<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>RSS2ICal - Convert RSS to Calendar</title>
-    <style>
-        body {
-            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif;
-            max-width: 800px;
-            margin: 0 auto;
-            padding: 2rem;
-            line-height: 1.6;
-            color: #333;
-        }
-        .container {
-            background: #f8f9fa;
-            border-radius: 8px;
-            padding: 2rem;
-            margin-bottom: 2rem;
-        }
-        h1 {
-            color: #2c3e50;
-            margin-bottom: 0.5rem;
-        }
-        .subtitle {
-            color: #666;
-            margin-bottom: 2rem;
-        }
-        label {
-            display: block;
-            margin-bottom: 0.5rem;
-            font-weight: 600;
-        }
-        input[type="url"] {
-            width: 100%;
-            padding: 0.75rem;
-            border: 2px solid #e1e5e9;
-            border-radius: 4px;
-            font-size: 1rem;
-            box-sizing: border-box;
-        }
-        input[type="url"]:focus {
-            outline: none;
-            border-color: #007bff;
-        }
-        button {
-            background: #007bff;
-            color: white;
-            border: none;
-            padding: 0.75rem 1.5rem;
-            border-radius: 4px;
-            font-size: 1rem;
-            cursor: pointer;
-            margin-top: 1rem;
-        }
-        button:hover {
-            background: #0056b3;
-        }
-        .result {
-            margin-top: 2rem;
-            padding: 1rem;
-            background: #d4edda;
-            border: 1px solid #c3e6cb;
-            border-radius: 4px;
-            display: none;
-        }
-        .result.show {
-            display: block;
-        }
-        .url-output {
-            background: white;
-            padding: 0.75rem;
-            border-radius: 4px;
-            word-break: break-all;
-            font-family: monospace;
-            margin: 0.5rem 0;
-        }
-        .copy-btn {
-            background: #28a745;
-            padding: 0.5rem 1rem;
-            font-size: 0.875rem;
-        }
-        .copy-btn:hover {
-            background: #1e7e34;
-        }
-        .example {
-            background: #fff3cd;
-            border: 1px solid #ffeaa7;
-            border-radius: 4px;
-            padding: 1rem;
-            margin-top: 1rem;
-        }
-        .example h3 {
-            margin-top: 0;
-            color: #856404;
-        }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <h1>RSS2ICal</h1>
-        <p class="subtitle">Convert any RSS feed to iCalendar format for Google Calendar, Apple Calendar, and more.</p>
-        
-        <form id="rssForm">
-            <label for="rssUrl">RSS Feed URL:</label>
-            <input 
-                type="url" 
-                id="rssUrl" 
-                name="rssUrl" 
-                placeholder="https://example.com/feed.rss"
-                required
-            >
-            <button type="submit">Generate Calendar URL</button>
-        </form>
-
-        <div id="result" class="result">
-            <h3>✅ Your Calendar URL:</h3>
-            <div id="calendarUrl" class="url-output"></div>
-            <button id="copyBtn" class="copy-btn">Copy URL</button>
-            <p><strong>Next steps:</strong></p>
-            <ul>
-                <li><strong>Google Calendar:</strong> Settings → Add Calendar → From URL → Paste the URL above</li>
-                <li><strong>Apple Calendar:</strong> File → New Calendar Subscription → Paste the URL above</li>
-            </ul>
-        </div>
-
-        <div class="example">
-            <h3>Example: SF Rec Parks Volunteer Events</h3>
-            <p>Try this RSS feed for SF Recreation & Parks volunteer events:</p>
-            <code>https://sfrecpark.org/RSSFeed.aspx?ModID=58&CID=Volunteer-Calendar-29</code>
-            <br><br>
-            <button onclick="fillExample()" style="background: #6c757d; padding: 0.5rem 1rem; font-size: 0.875rem;">Use This Example</button>
-        </div>
-    </div>
-
-    <script>
-        function fillExample() {
-            document.getElementById('rssUrl').value = 'https://sfrecpark.org/RSSFeed.aspx?ModID=58&CID=Volunteer-Calendar-29';
-        }
-
-        document.getElementById('rssForm').addEventListener('submit', function(e) {
-            e.preventDefault();
-            
-            const rssUrl = document.getElementById('rssUrl').value;
-            const baseUrl = window.location.origin;
-            const encodedUrl = encodeURIComponent(rssUrl);
-            const calendarUrl = baseUrl + '/calendar?url=' + encodedUrl;
-            
-            document.getElementById('calendarUrl').textContent = calendarUrl;
-            document.getElementById('result').classList.add('show');
-            
-            // Scroll to result
-            document.getElementById('result').scrollIntoView({ behavior: 'smooth' });
-        });
-
-        document.getElementById('copyBtn').addEventListener('click', function() {
-            const urlText = document.getElementById('calendarUrl').textContent;
-            navigator.clipboard.writeText(urlText).then(function() {
-                const btn = document.getElementById('copyBtn');
-                const originalText = btn.textContent;
-                btn.textContent = 'Copied!';
-                btn.style.background = '#20c997';
-                setTimeout(function() {
-                    btn.textContent = originalText;
-                    btn.style.background = '#28a745';
-                }, 2000);
-            });
-        });
-    </script>
-</body>
-</html>`
-
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(html))
+	w.Write(indexHTML)
 }
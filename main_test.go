@@ -1,13 +1,35 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 )
 
+func TestMain(m *testing.M) {
+	// Mock feeds are served by httptest on loopback
+	allowPrivateNetworks = true
+	// Failing mock feeds are retried without waiting
+	retryBackoff = time.Millisecond
+	os.Exit(m.Run())
+}
+
 // Mock RSS feed for testing
 const mockRSSFeed = `<?xml version="1.0" encoding="UTF-8"?>
 <rss version="2.0">
@@ -39,18 +61,54 @@ func TestParseTime(t *testing.T) {
 		{"Mon, 27 Jul 2025 12:00:00 GMT", true},
 		{"Mon, 27 Jul 2025 12:00:00 -0700", true},
 		{"2025-07-27T12:00:00Z", true},
-		{"invalid date", false}, // should fallback to current time
+		{"invalid date", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		result, err := parseTime(test.input)
+		if test.expected && (err != nil || result.IsZero()) {
+			t.Errorf("Expected successful parsing for %s, got %v, %v", test.input, result, err)
+		}
+		if !test.expected && (err == nil || !result.IsZero()) {
+			t.Errorf("Expected an error and zero time for %q, got %v, %v", test.input, result, err)
+		}
+	}
+}
+
+func TestParseDateFormats(t *testing.T) {
+	noon := time.Date(2025, time.July, 27, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		input    string
+		expected time.Time
+	}{
+		{"Mon, 27 Jul 25 12:00 GMT", noon},
+		{"Mon, 27 Jul 25 12:00 +0000", noon},
+		{"Mon, 27 Jul 25 12:00:30 GMT", noon.Add(30 * time.Second)},
+		{"Mon, 27 Jul 25 14:00:00 +0200", noon},
+		{"Mon, 27 Jul 2025 12:00 GMT", noon},
+		{"Mon, 27 Jul 2025 05:00 -0700", noon},
+		{"Mon, 7 Jul 25 12:00 GMT", noon.AddDate(0, 0, -20)},
+		{"27 Jul 25 12:00 GMT", noon},
+		{"27 Jul 25 08:00 -0400", noon},
+		// Two-digit years from 69 on are in the 1900s
+		{"Sun, 27 Jul 97 12:00 GMT", time.Date(1997, time.July, 27, 12, 0, 0, 0, time.UTC)},
 	}
 
 	for _, test := range tests {
-		result := parseTime(test.input)
-		if test.expected && result.IsZero() {
-			t.Errorf("Expected successful parsing for %s, got zero time", test.input)
+		result, err := parseTimeIn(test.input, time.UTC)
+		if err != nil {
+			t.Errorf("Expected %q to parse, got error: %v", test.input, err)
+			continue
 		}
-		if !test.expected && result.Before(time.Now().Add(-time.Minute)) {
-			t.Errorf("Expected fallback to current time for %s", test.input)
+		if !result.Equal(test.expected) {
+			t.Errorf("Expected %q to parse as %v, got %v", test.input, test.expected, result)
 		}
 	}
+
+	if _, err := parseTime("27/07/2025"); err == nil {
+		t.Error("Expected an error for an unrecognized date")
+	}
 }
 
 func TestRSSToICal(t *testing.T) {
@@ -61,7 +119,7 @@ func TestRSSToICal(t *testing.T) {
 	}
 
 	// Convert to iCal
-	ical, err := rssToICal(rss)
+	ical, err := rssToICal(rss, defaultCalendarOptions())
 	if err != nil {
 		t.Fatalf("Failed to convert RSS to iCal: %v", err)
 	}
@@ -244,7 +302,7 @@ func TestCalendarHandlerCaching(t *testing.T) {
 }
 
 func TestFetchRSSInvalidURL(t *testing.T) {
-	_, err := fetchRSS("invalid-url")
+	_, err := fetchRSS(context.Background(), "invalid-url")
 	if err == nil {
 		t.Error("Expected error for invalid URL")
 	}
@@ -257,39 +315,20 @@ func TestFetchRSS404(t *testing.T) {
 	}))
 	defer mockServer.Close()
 
-	_, err := fetchRSS(mockServer.URL)
+	_, err := fetchRSS(context.Background(), mockServer.URL)
 	if err == nil {
 		t.Error("Expected error for 404 response")
 	}
 }
 
-// Helper function to parse RSS from string for testing
+// Helper function to parse RSS from string for testing, using the same XML
+// path as fetchRSS
 func parseRSSFromString(data string, rss *RSS) error {
-	return parseRSSBytes([]byte(data), rss)
-}
-
-// Helper function for parsing RSS bytes
-func parseRSSBytes(data []byte, rss *RSS) error {
-	// This would use the same XML unmarshaling as fetchRSS
-	// For now, manually populate for testing
-	rss.Channel.Title = "Test RSS Feed"
-	rss.Channel.Description = "Test RSS Description"
-	rss.Channel.Items = []Item{
-		{
-			Title:       "Test Item 1",
-			Description: "Test Description 1",
-			Link:        "https://example.com/1",
-			PubDate:     "Mon, 27 Jul 2025 12:00:00 GMT",
-			GUID:        "test-guid-1",
-		},
-		{
-			Title:       "Test Item 2",
-			Description: "Test Description 2",
-			Link:        "https://example.com/2",
-			PubDate:     "Mon, 27 Jul 2025 13:00:00 GMT",
-			GUID:        "test-guid-2",
-		},
+	parsed, err := parseRSS([]byte(data))
+	if err != nil {
+		return err
 	}
+	*rss = *parsed
 	return nil
 }
 
@@ -372,3 +411,2346 @@ func TestHomeHandlerJavaScriptFunctionality(t *testing.T) {
 		}
 	}
 }
+
+func TestRSSToICalDuration(t *testing.T) {
+	rss := &RSS{}
+	if err := parseRSSFromString(mockRSSFeed, rss); err != nil {
+		t.Fatalf("Failed to parse mock RSS: %v", err)
+	}
+
+	opts := defaultCalendarOptions()
+	opts.Duration = 30 * time.Minute
+	ical, err := rssToICal(rss, opts)
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+
+	if !strings.Contains(ical, "DTSTART:20250727T120000Z") || !strings.Contains(ical, "DTEND:20250727T123000Z") {
+		t.Errorf("Expected 30 minute event, got: %s", ical)
+	}
+}
+
+func TestRSSToICalZeroDuration(t *testing.T) {
+	rss := &RSS{}
+	if err := parseRSSFromString(mockRSSFeed, rss); err != nil {
+		t.Fatalf("Failed to parse mock RSS: %v", err)
+	}
+
+	opts := defaultCalendarOptions()
+	opts.Duration = 0
+	ical, err := rssToICal(rss, opts)
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+
+	if !strings.Contains(ical, "DTSTART:20250727T120000Z") || !strings.Contains(ical, "DTEND:20250727T120000Z") {
+		t.Errorf("Expected DTEND to equal DTSTART, got: %s", ical)
+	}
+}
+
+func TestCalendarHandlerInvalidDuration(t *testing.T) {
+	req := httptest.NewRequest("GET", "/calendar?url=https://test.com&duration=forever", nil)
+	w := httptest.NewRecorder()
+
+	calendarHandler(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code 400, got %d", w.Code)
+	}
+
+	if body := w.Body.String(); !strings.Contains(body, "invalid duration") {
+		t.Errorf("Expected invalid duration message, got '%s'", body)
+	}
+}
+
+func TestRSSToICalAllDay(t *testing.T) {
+	rss := &RSS{}
+	if err := parseRSSFromString(mockRSSFeed, rss); err != nil {
+		t.Fatalf("Failed to parse mock RSS: %v", err)
+	}
+
+	opts := defaultCalendarOptions()
+	opts.AllDay = true
+	ical, err := rssToICal(rss, opts)
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+
+	if !strings.Contains(ical, "DTSTART;VALUE=DATE:20250727") {
+		t.Errorf("Expected DATE-valued DTSTART, got: %s", ical)
+	}
+	if !strings.Contains(ical, "DTEND;VALUE=DATE:20250728") {
+		t.Errorf("Expected DTEND on the following day, got: %s", ical)
+	}
+	if strings.Contains(ical, "T000000") {
+		t.Errorf("Expected no DATE-TIME midnight values, got: %s", ical)
+	}
+}
+
+func TestRSSToICalTimezone(t *testing.T) {
+	rss := &RSS{}
+	if err := parseRSSFromString(mockRSSFeed, rss); err != nil {
+		t.Fatalf("Failed to parse mock RSS: %v", err)
+	}
+
+	opts, err := parseCalendarOptions(map[string][]string{"tz": {"America/New_York"}})
+	if err != nil {
+		t.Fatalf("Failed to parse options: %v", err)
+	}
+	ical, err := rssToICal(rss, opts)
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+
+	// 12:00 GMT is 08:00 EDT in July
+	expected := []string{
+		"X-WR-TIMEZONE:America/New_York",
+		"DTSTART;TZID=America/New_York:20250727T080000",
+		"DTEND;TZID=America/New_York:20250727T090000",
+	}
+	for _, exp := range expected {
+		if !strings.Contains(ical, exp) {
+			t.Errorf("Expected iCal to contain '%s', got: %s", exp, ical)
+		}
+	}
+}
+
+func TestParseTimeInBareTime(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("Failed to load location: %v", err)
+	}
+
+	result, err := parseTimeIn("2025-07-27T12:00:00", loc)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := time.Date(2025, time.July, 27, 12, 0, 0, 0, loc)
+	if !result.Equal(expected) {
+		t.Errorf("Expected bare time interpreted in America/New_York (%v), got %v", expected, result)
+	}
+}
+
+func TestCalendarHandlerInvalidTimezone(t *testing.T) {
+	req := httptest.NewRequest("GET", "/calendar?url=https://test.com&tz=Not/AZone", nil)
+	w := httptest.NewRecorder()
+
+	calendarHandler(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code 400, got %d", w.Code)
+	}
+}
+
+func TestRSSToICalStripsHTML(t *testing.T) {
+	rss := &RSS{Channel: Channel{Items: []Item{{
+		Title:       "HTML Item",
+		Description: "<p>Hello <b>World</b>&amp;more</p>",
+		GUID:        "html-guid",
+	}}}}
+
+	ical, err := rssToICal(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+	if !strings.Contains(ical, "DESCRIPTION:Hello World&more") {
+		t.Errorf("Expected plain text description, got: %s", ical)
+	}
+
+	opts := defaultCalendarOptions()
+	opts.RawHTML = true
+	ical, err = rssToICal(rss, opts)
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+	if !strings.Contains(ical, `DESCRIPTION:<p>Hello <b>World</b>&amp\;more</p>`) {
+		t.Errorf("Expected raw HTML description, got: %s", ical)
+	}
+}
+
+func TestRSSToICalAltDescription(t *testing.T) {
+	rss := &RSS{Channel: Channel{Items: []Item{
+		{Title: "HTML Item", Description: "<p>Hello <b>World</b></p>", GUID: "html-guid"},
+		{Title: "Plain Item", Description: "Just text", GUID: "plain-guid"},
+	}}}
+
+	ical, err := rssToICal(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+
+	for _, exp := range []string{
+		"DESCRIPTION:Hello World",
+		"X-ALT-DESC;FMTTYPE=text/html:<p>Hello <b>World</b></p>",
+	} {
+		if !strings.Contains(ical, exp) {
+			t.Errorf("Expected iCal to contain '%s', got: %s", exp, ical)
+		}
+	}
+	if count := strings.Count(ical, "X-ALT-DESC"); count != 1 {
+		t.Errorf("Expected X-ALT-DESC only for the HTML item, got %d", count)
+	}
+
+	// Raw descriptions already carry the HTML
+	opts := defaultCalendarOptions()
+	opts.RawHTML = true
+	ical, err = rssToICal(rss, opts)
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+	if strings.Contains(ical, "X-ALT-DESC") {
+		t.Error("Expected no X-ALT-DESC with html=raw")
+	}
+}
+
+func TestCalendarHandlerConditionalFetch(t *testing.T) {
+	bodyCount := 0
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		bodyCount++
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Mon, 27 Jul 2025 12:00:00 GMT")
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(mockRSSFeed))
+	}))
+	defer mockServer.Close()
+
+	// Clear cache for clean test
+	cache = &Cache{}
+
+	req1 := httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil)
+	w1 := httptest.NewRecorder()
+	calendarHandler(w1, req1)
+
+	entry, ok := cache.Lookup(mockServer.URL)
+	if !ok || entry.validators.ETag != `"v1"` {
+		t.Fatalf("Expected cached ETag, got %+v", entry.validators)
+	}
+
+	// Expire the entry so the next request revalidates upstream
+	entry.timestamp = time.Now().Add(-10 * time.Minute)
+	cache.entries[mockServer.URL] = entry
+
+	req2 := httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil)
+	w2 := httptest.NewRecorder()
+	calendarHandler(w2, req2)
+
+	if bodyCount != 1 {
+		t.Errorf("Expected 1 full body fetch, got %d", bodyCount)
+	}
+
+	if w2.Code != http.StatusOK {
+		t.Errorf("Expected status code 200, got %d", w2.Code)
+	}
+
+	if w1.Body.String() != w2.Body.String() {
+		t.Errorf("Revalidated response differs from original")
+	}
+
+	if _, ok := cache.Get(mockServer.URL); !ok {
+		t.Error("Expected 304 to refresh the cache entry")
+	}
+}
+
+func TestFetchFeedSendsValidators(t *testing.T) {
+	var ifNoneMatch, ifModifiedSince string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ifNoneMatch = r.Header.Get("If-None-Match")
+		ifModifiedSince = r.Header.Get("If-Modified-Since")
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer mockServer.Close()
+
+	validators := Validators{ETag: `"abc"`, LastModified: "Mon, 27 Jul 2025 12:00:00 GMT"}
+	result, err := fetchFeed(context.Background(), mockServer.URL, validators)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !result.NotModified {
+		t.Error("Expected NotModified result for 304 response")
+	}
+	if ifNoneMatch != validators.ETag || ifModifiedSince != validators.LastModified {
+		t.Errorf("Expected conditional headers, got If-None-Match=%q If-Modified-Since=%q", ifNoneMatch, ifModifiedSince)
+	}
+}
+
+func TestCacheConfiguredTTL(t *testing.T) {
+	defer func(ttl time.Duration) { cacheTTL = ttl }(cacheTTL)
+	cacheTTL = time.Minute
+
+	cache := &Cache{}
+	url := "https://test.com/rss.xml"
+	data := "test calendar data"
+
+	// Within the configured TTL
+	cache.Set(url, data)
+	cache.entries[url] = CacheEntry{
+		data:      data,
+		timestamp: time.Now().Add(-30 * time.Second),
+	}
+	if _, ok := cache.Get(url); !ok {
+		t.Error("Expected cache hit within configured TTL")
+	}
+
+	// Past the configured TTL but within the default
+	cache.entries[url] = CacheEntry{
+		data:      data,
+		timestamp: time.Now().Add(-2 * time.Minute),
+	}
+	if cached, ok := cache.Get(url); ok {
+		t.Errorf("Expected cache miss past configured TTL, got: %s", cached)
+	}
+}
+
+func TestDurationFromEnv(t *testing.T) {
+	t.Setenv("TEST_DURATION", "")
+	if d := durationFromEnv("TEST_DURATION", 5*time.Minute); d != 5*time.Minute {
+		t.Errorf("Expected default for unset variable, got %v", d)
+	}
+
+	t.Setenv("TEST_DURATION", "90s")
+	if d := durationFromEnv("TEST_DURATION", 5*time.Minute); d != 90*time.Second {
+		t.Errorf("Expected 90s, got %v", d)
+	}
+
+	t.Setenv("TEST_DURATION", "often")
+	if d := durationFromEnv("TEST_DURATION", 5*time.Minute); d != 5*time.Minute {
+		t.Errorf("Expected default for invalid value, got %v", d)
+	}
+}
+
+func TestIntFromEnv(t *testing.T) {
+	t.Setenv("TEST_INT", "")
+	if n := intFromEnv("TEST_INT", 1000); n != 1000 {
+		t.Errorf("Expected default for unset variable, got %d", n)
+	}
+
+	t.Setenv("TEST_INT", "50")
+	if n := intFromEnv("TEST_INT", 1000); n != 50 {
+		t.Errorf("Expected 50, got %d", n)
+	}
+
+	t.Setenv("TEST_INT", "-5")
+	if n := intFromEnv("TEST_INT", 1000); n != 1000 {
+		t.Errorf("Expected default for invalid value, got %d", n)
+	}
+}
+
+func TestNonNegativeIntFromEnv(t *testing.T) {
+	t.Setenv("TEST_INT", "0")
+	if n := nonNegativeIntFromEnv("TEST_INT", 10); n != 0 {
+		t.Errorf("Expected 0, got %d", n)
+	}
+
+	t.Setenv("TEST_INT", "-1")
+	if n := nonNegativeIntFromEnv("TEST_INT", 10); n != 10 {
+		t.Errorf("Expected default for negative value, got %d", n)
+	}
+}
+
+func TestRSSToICalDateFromContent(t *testing.T) {
+	rss := &RSS{Channel: Channel{Items: []Item{
+		{
+			Title:       "Meetup",
+			Description: "<p>Join us on <b>January 5, 2025 7:00 PM</b></p>",
+			PubDate:     "Mon, 27 Jul 2025 12:00:00 GMT",
+			GUID:        "content-date",
+		},
+		{
+			Title:       "No date",
+			Description: "Nothing scheduled",
+			PubDate:     "Mon, 27 Jul 2025 13:00:00 GMT",
+			GUID:        "pubdate-fallback",
+		},
+	}}}
+
+	opts := defaultCalendarOptions()
+	opts.DateFromContent = true
+	ical, err := rssToICal(rss, opts)
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+
+	if !strings.Contains(ical, "DTSTART:20250105T190000Z") {
+		t.Errorf("Expected start from description date, got: %s", ical)
+	}
+	if !strings.Contains(ical, "DTSTART:20250727T130000Z") {
+		t.Errorf("Expected pubDate fallback, got: %s", ical)
+	}
+}
+
+func TestRSSToICalDublinCoreDate(t *testing.T) {
+	feed := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/">
+  <channel>
+    <title>DC Feed</title>
+    <item>
+      <title>DC Item</title>
+      <dc:date>2025-07-27T15:30:00Z</dc:date>
+      <guid>dc-guid-1</guid>
+    </item>
+  </channel>
+</rss>`
+
+	rss, err := parseRSS([]byte(feed))
+	if err != nil {
+		t.Fatalf("Failed to parse feed: %v", err)
+	}
+	if rss.Channel.Items[0].DCDate != "2025-07-27T15:30:00Z" {
+		t.Errorf("Expected dc:date to be parsed, got '%s'", rss.Channel.Items[0].DCDate)
+	}
+
+	ical, err := rssToICal(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+	if !strings.Contains(ical, "DTSTART:20250727T153000Z") {
+		t.Errorf("Expected DTSTART from dc:date, got: %s", ical)
+	}
+}
+
+func TestCalendarHandlerHonorsFeedTTL(t *testing.T) {
+	requestCount := 0
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCount++
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(strings.Replace(mockRSSFeed, "<channel>", "<channel>\n    <ttl>60</ttl>", 1)))
+	}))
+	defer mockServer.Close()
+
+	// Clear cache for clean test
+	cache = &Cache{}
+
+	req1 := httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil)
+	calendarHandler(httptest.NewRecorder(), req1)
+
+	// Age the entry past the 5 minute global default
+	entry := cache.entries[mockServer.URL]
+	entry.timestamp = time.Now().Add(-10 * time.Minute)
+	cache.entries[mockServer.URL] = entry
+
+	req2 := httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil)
+	calendarHandler(httptest.NewRecorder(), req2)
+
+	if requestCount != 1 {
+		t.Errorf("Expected feed <ttl> to keep the entry cached, got %d RSS requests", requestCount)
+	}
+}
+
+func TestCalendarHandlerGzip(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(mockRSSFeed))
+	}))
+	defer mockServer.Close()
+
+	// Clear cache for clean test
+	cache = &Cache{}
+
+	req := httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	w := httptest.NewRecorder()
+
+	calendarHandler(w, req)
+
+	if encoding := w.Header().Get("Content-Encoding"); encoding != "gzip" {
+		t.Fatalf("Expected Content-Encoding gzip, got '%s'", encoding)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "text/calendar; charset=utf-8" {
+		t.Errorf("Expected Content-Type 'text/calendar; charset=utf-8', got '%s'", contentType)
+	}
+
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("Failed to open gzip body: %v", err)
+	}
+	body, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("Failed to decompress body: %v", err)
+	}
+	if !strings.Contains(string(body), "BEGIN:VCALENDAR") {
+		t.Errorf("Expected decompressed iCalendar content, got: %s", body)
+	}
+
+	// The cached entry is uncompressed, so plain clients share it
+	if cached, ok := cache.Get(mockServer.URL); !ok || cached != string(body) {
+		t.Error("Expected cache to hold the uncompressed calendar")
+	}
+
+	plainReq := httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil)
+	plainW := httptest.NewRecorder()
+	calendarHandler(plainW, plainReq)
+	if plainW.Header().Get("Content-Encoding") != "" || plainW.Body.String() != string(body) {
+		t.Error("Expected uncompressed response for client without Accept-Encoding")
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, gzip;q=0.8", true},
+		{"gzip;q=0", false},
+		{"br", false},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/calendar", nil)
+		req.Header.Set("Accept-Encoding", test.header)
+		if result := acceptsGzip(req); result != test.expected {
+			t.Errorf("acceptsGzip(%q) = %v, expected %v", test.header, result, test.expected)
+		}
+	}
+}
+
+func TestFetchRSSGzip(t *testing.T) {
+	var acceptEncoding string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding = r.Header.Get("Accept-Encoding")
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		gz.Write([]byte(mockRSSFeed))
+	}))
+	defer mockServer.Close()
+
+	rss, err := fetchRSS(context.Background(), mockServer.URL)
+	if err != nil {
+		t.Fatalf("Failed to fetch gzipped RSS: %v", err)
+	}
+
+	if acceptEncoding != "gzip" {
+		t.Errorf("Expected Accept-Encoding gzip, got '%s'", acceptEncoding)
+	}
+	if rss.Channel.Title != "Test RSS Feed" || len(rss.Channel.Items) != 2 {
+		t.Errorf("Expected parsed gzipped feed, got %+v", rss.Channel)
+	}
+}
+
+func TestFetchRSSIgnoredGzip(t *testing.T) {
+	// Server ignores Accept-Encoding and sends plain XML
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(mockRSSFeed))
+	}))
+	defer mockServer.Close()
+
+	rss, err := fetchRSS(context.Background(), mockServer.URL)
+	if err != nil {
+		t.Fatalf("Failed to fetch plain RSS: %v", err)
+	}
+	if len(rss.Channel.Items) != 2 {
+		t.Errorf("Expected 2 items, got %d", len(rss.Channel.Items))
+	}
+}
+
+func TestCalendarHandlerCORSPreflight(t *testing.T) {
+	req := httptest.NewRequest("OPTIONS", "/calendar?url=https://test.com", nil)
+	req.Header.Set("Origin", "https://dashboard.example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	w := httptest.NewRecorder()
+
+	calendarHandler(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status code 204, got %d", w.Code)
+	}
+	if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != "*" {
+		t.Errorf("Expected Access-Control-Allow-Origin '*', got '%s'", origin)
+	}
+	if methods := w.Header().Get("Access-Control-Allow-Methods"); methods != "GET, HEAD" {
+		t.Errorf("Expected Access-Control-Allow-Methods 'GET, HEAD', got '%s'", methods)
+	}
+}
+
+func TestCalendarHandlerCORSOrigin(t *testing.T) {
+	defer func(origin string) { corsOrigin = origin }(corsOrigin)
+	corsOrigin = "https://dashboard.example.com"
+
+	req := httptest.NewRequest("GET", "/calendar", nil)
+	w := httptest.NewRecorder()
+
+	calendarHandler(w, req)
+
+	if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != corsOrigin {
+		t.Errorf("Expected configured origin '%s', got '%s'", corsOrigin, origin)
+	}
+}
+
+func TestRSSToICalLimit(t *testing.T) {
+	rss := &RSS{Channel: Channel{Items: []Item{
+		{Title: "Day 1", PubDate: "Mon, 21 Jul 2025 12:00:00 GMT", GUID: "day-1"},
+		{Title: "Day 4", PubDate: "Thu, 24 Jul 2025 12:00:00 GMT", GUID: "day-4"},
+		{Title: "Day 2", PubDate: "Tue, 22 Jul 2025 12:00:00 GMT", GUID: "day-2"},
+		{Title: "Day 5", PubDate: "Fri, 25 Jul 2025 12:00:00 GMT", GUID: "day-5"},
+		{Title: "Day 3", PubDate: "Wed, 23 Jul 2025 12:00:00 GMT", GUID: "day-3"},
+	}}}
+
+	opts := defaultCalendarOptions()
+	opts.Limit = 2
+	ical, err := rssToICal(rss, opts)
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+
+	if count := strings.Count(ical, "BEGIN:VEVENT"); count != 2 {
+		t.Errorf("Expected 2 VEVENTs, got %d", count)
+	}
+	for _, uid := range []string{"UID:day-5", "UID:day-4"} {
+		if !strings.Contains(ical, uid) {
+			t.Errorf("Expected newest item %s, got: %s", uid, ical)
+		}
+	}
+}
+
+func TestRSSToICalLocation(t *testing.T) {
+	feed := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:georss="http://www.georss.org/georss">
+  <channel>
+    <title>Events</title>
+    <item>
+      <title>Meetup</title>
+      <location>Main Library, Room 2</location>
+      <venue>Town Hall</venue>
+      <georss:point>37.77 -122.41</georss:point>
+      <guid>with-location</guid>
+    </item>
+    <item>
+      <title>Online</title>
+      <guid>without-location</guid>
+    </item>
+  </channel>
+</rss>`
+
+	rss, err := parseRSS([]byte(feed))
+	if err != nil {
+		t.Fatalf("Failed to parse feed: %v", err)
+	}
+
+	ical, err := rssToICal(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+	if !strings.Contains(ical, `LOCATION:Main Library\, Room 2`) {
+		t.Errorf("Expected LOCATION from <location>, got: %s", ical)
+	}
+	if count := strings.Count(ical, "LOCATION:"); count != 1 {
+		t.Errorf("Expected LOCATION only on the item that has one, got %d", count)
+	}
+
+	opts := defaultCalendarOptions()
+	opts.LocationField = "venue"
+	ical, _ = rssToICal(rss, opts)
+	if !strings.Contains(ical, "LOCATION:Town Hall") {
+		t.Errorf("Expected LOCATION from configured <venue>, got: %s", ical)
+	}
+
+	opts.LocationField = "georss:point"
+	ical, _ = rssToICal(rss, opts)
+	if !strings.Contains(ical, "LOCATION:37.77 -122.41") {
+		t.Errorf("Expected LOCATION from <georss:point>, got: %s", ical)
+	}
+}
+
+func TestRSSToICalCategories(t *testing.T) {
+	feed := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+  <channel>
+    <title>Team Blog</title>
+    <item>
+      <title>Launch</title>
+      <category>Engineering</category>
+      <category> Product </category>
+      <category>Engineering</category>
+      <guid>tagged</guid>
+    </item>
+    <item>
+      <title>Escaped</title>
+      <category>Research, Development</category>
+      <guid>escaped</guid>
+    </item>
+    <item>
+      <title>Untagged</title>
+      <guid>untagged</guid>
+    </item>
+  </channel>
+</rss>`
+
+	rss, err := parseRSS([]byte(feed))
+	if err != nil {
+		t.Fatalf("Failed to parse feed: %v", err)
+	}
+
+	ical, err := rssToICal(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+
+	if !strings.Contains(ical, "CATEGORIES:Engineering,Product\r\n") {
+		t.Errorf("Expected de-duplicated CATEGORIES line, got: %s", ical)
+	}
+	if !strings.Contains(ical, `CATEGORIES:Research\, Development`) {
+		t.Errorf("Expected commas within a category to stay escaped, got: %s", ical)
+	}
+	if count := strings.Count(ical, "CATEGORIES:"); count != 2 {
+		t.Errorf("Expected no CATEGORIES line for untagged item, got %d lines", count)
+	}
+}
+
+func TestRSSToICalAlarm(t *testing.T) {
+	rss, err := parseRSS([]byte(mockRSSFeed))
+	if err != nil {
+		t.Fatalf("Failed to parse RSS: %v", err)
+	}
+
+	opts := defaultCalendarOptions()
+	opts.Alarm = 15 * time.Minute
+	ical, err := rssToICal(rss, opts)
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+
+	if count := strings.Count(ical, "BEGIN:VALARM"); count != strings.Count(ical, "BEGIN:VEVENT") {
+		t.Errorf("Expected one VALARM per event, got %d", count)
+	}
+	for _, exp := range []string{"ACTION:DISPLAY", "TRIGGER:-PT15M"} {
+		if !strings.Contains(ical, exp) {
+			t.Errorf("Expected iCal to contain '%s', got: %s", exp, ical)
+		}
+	}
+
+	ical, err = rssToICal(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+	if strings.Contains(ical, "BEGIN:VALARM") {
+		t.Error("Expected no VALARM without an alarm option")
+	}
+}
+
+func TestICalDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		15 * time.Minute:          "PT15M",
+		90 * time.Minute:          "PT1H30M",
+		26 * time.Hour:            "PT26H",
+		time.Hour + 5*time.Second: "PT1H5S",
+		500 * time.Millisecond:    "PT0S",
+	}
+	for d, expected := range tests {
+		if got := icalDuration(d); got != expected {
+			t.Errorf("icalDuration(%v) = %s, expected %s", d, got, expected)
+		}
+	}
+}
+
+func TestRSSToICalMissingGUID(t *testing.T) {
+	rss := &RSS{Channel: Channel{
+		Title: "No GUIDs",
+		Items: []Item{
+			{Title: "First", Link: "https://example.com/1", PubDate: "Sun, 27 Jul 2025 12:00:00 +0000"},
+			{Title: "Second", Link: "https://example.com/2", PubDate: "Sun, 27 Jul 2025 12:00:00 +0000"},
+		},
+	}}
+
+	ical, err := rssToICal(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+
+	first, second := rss.Channel.Items[0].uid(), rss.Channel.Items[1].uid()
+	if first == "" || first == second {
+		t.Fatalf("Expected distinct synthesized UIDs, got %q and %q", first, second)
+	}
+	for _, uid := range []string{first, second} {
+		if !strings.Contains(ical, "UID:"+uid) {
+			t.Errorf("Expected iCal to contain UID %s, got: %s", uid, ical)
+		}
+	}
+
+	again, _ := rssToICal(rss, defaultCalendarOptions())
+	if count := strings.Count(again, "UID:"+first); count != 1 {
+		t.Errorf("Expected synthesized UID to be stable across conversions")
+	}
+}
+
+func TestRSSToICalDuplicateGUID(t *testing.T) {
+	rss := &RSS{Channel: Channel{
+		Title: "Reused GUIDs",
+		Items: []Item{
+			{Title: "First", GUID: "same"},
+			{Title: "Second", GUID: "same"},
+			{Title: "Third", GUID: "same"},
+		},
+	}}
+
+	ical, err := rssToICal(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+
+	for _, exp := range []string{"UID:same\r\n", "UID:same-2\r\n", "UID:same-3\r\n"} {
+		if !strings.Contains(ical, exp) {
+			t.Errorf("Expected iCal to contain %q, got: %s", exp, ical)
+		}
+	}
+}
+
+func TestServeShutdown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	server := newServer(ln.Addr().String())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- serve(ctx, server, ln)
+	}()
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/health")
+	if err != nil {
+		t.Fatalf("Failed to reach server: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code 200, got %d", resp.StatusCode)
+	}
+
+	cancel()
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Errorf("Expected clean shutdown, got: %v", err)
+		}
+	case <-time.After(shutdownTimeout):
+		t.Fatal("Server did not shut down")
+	}
+}
+
+func TestFetchFeedRedirectLimit(t *testing.T) {
+	var mockServer *httptest.Server
+	mockServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hop, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		if r.Header.Get("User-Agent") != defaultUserAgent() {
+			t.Errorf("Expected User-Agent preserved on hop %d, got %q", hop, r.Header.Get("User-Agent"))
+		}
+		if hop < 5 {
+			http.Redirect(w, r, mockServer.URL+"/"+strconv.Itoa(hop+1), http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(mockRSSFeed))
+	}))
+	defer mockServer.Close()
+
+	defer func(n int) { maxRedirects = n }(maxRedirects)
+
+	maxRedirects = 5
+	if _, err := fetchRSS(context.Background(), mockServer.URL+"/0"); err != nil {
+		t.Errorf("Expected 5 redirects to be followed, got: %v", err)
+	}
+
+	maxRedirects = 3
+	_, err := fetchRSS(context.Background(), mockServer.URL+"/0")
+	if err == nil || !strings.Contains(err.Error(), "stopped after 3 redirects") {
+		t.Errorf("Expected redirect limit error, got: %v", err)
+	}
+}
+
+func TestRSSToICalChannelImage(t *testing.T) {
+	feed := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+  <channel>
+    <title>Branded Feed</title>
+    <image>
+      <url>https://example.com/logo.png?size=64,64</url>
+      <title>Branded Feed</title>
+      <link>https://example.com</link>
+    </image>
+    <item>
+      <title>Post</title>
+      <guid>post</guid>
+    </item>
+  </channel>
+</rss>`
+
+	rss, err := parseRSS([]byte(feed))
+	if err != nil {
+		t.Fatalf("Failed to parse feed: %v", err)
+	}
+	if rss.Channel.Image.Link != "https://example.com" {
+		t.Errorf("Expected image link, got '%s'", rss.Channel.Image.Link)
+	}
+
+	ical, err := rssToICal(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+
+	for _, exp := range []string{
+		"IMAGE;VALUE=URI:https://example.com/logo.png?size=64,64",
+		"X-WR-CALNAME:Branded Feed",
+	} {
+		if !strings.Contains(ical, exp) {
+			t.Errorf("Expected iCal to contain '%s', got: %s", exp, ical)
+		}
+	}
+
+	ical, err = rssToICal(&RSS{Channel: Channel{Title: "Plain"}}, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+	if strings.Contains(ical, "IMAGE") {
+		t.Error("Expected no IMAGE property without a channel image")
+	}
+}
+
+func TestRSSToICalEnclosures(t *testing.T) {
+	feed := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+  <channel>
+    <title>Podcast</title>
+    <item>
+      <title>Episode 1</title>
+      <guid>episode-1</guid>
+      <enclosure url="https://example.com/ep1.mp3" type="audio/mpeg" length="12345"/>
+      <enclosure url="https://example.com/ep1.pdf" type="application/pdf" length="678"/>
+    </item>
+    <item>
+      <title>Announcement</title>
+      <guid>announcement</guid>
+    </item>
+  </channel>
+</rss>`
+
+	rss, err := parseRSS([]byte(feed))
+	if err != nil {
+		t.Fatalf("Failed to parse feed: %v", err)
+	}
+	if enclosures := rss.Channel.Items[0].Enclosures; len(enclosures) != 2 || enclosures[0].Length != 12345 {
+		t.Fatalf("Expected 2 parsed enclosures, got %+v", enclosures)
+	}
+
+	ical, err := rssToICal(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+
+	for _, exp := range []string{
+		"ATTACH;FMTTYPE=audio/mpeg:https://example.com/ep1.mp3",
+		"ATTACH;FMTTYPE=application/pdf:https://example.com/ep1.pdf",
+	} {
+		if !strings.Contains(ical, exp) {
+			t.Errorf("Expected iCal to contain '%s', got: %s", exp, ical)
+		}
+	}
+	if count := strings.Count(ical, "ATTACH"); count != 2 {
+		t.Errorf("Expected 2 ATTACH properties, got %d", count)
+	}
+}
+
+func TestRSSToICalSortOrder(t *testing.T) {
+	rss := &RSS{Channel: Channel{
+		Title: "Unordered",
+		Items: []Item{
+			{Title: "Middle", GUID: "middle", PubDate: "Mon, 28 Jul 2025 12:00:00 +0000"},
+			{Title: "Undated", GUID: "undated", PubDate: "someday"},
+			{Title: "Newest", GUID: "newest", PubDate: "Tue, 29 Jul 2025 12:00:00 +0000"},
+			{Title: "Oldest", GUID: "oldest", PubDate: "Sun, 27 Jul 2025 12:00:00 +0000"},
+		},
+	}}
+
+	tests := map[string][]string{
+		"asc":  {"oldest", "middle", "newest", "undated"},
+		"desc": {"newest", "middle", "oldest", "undated"},
+	}
+	for sortOrder, expected := range tests {
+		opts, err := parseCalendarOptions(url.Values{"sort": {sortOrder}})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		ical, err := rssToICal(rss, opts)
+		if err != nil {
+			t.Fatalf("Failed to convert RSS to iCal: %v", err)
+		}
+
+		last := -1
+		for _, uid := range expected {
+			index := strings.Index(ical, "UID:"+uid+"\r\n")
+			if index < 0 || index < last {
+				t.Errorf("sort=%s: expected %v order, got: %s", sortOrder, expected, ical)
+				break
+			}
+			last = index
+		}
+	}
+
+	if _, err := parseCalendarOptions(url.Values{"sort": {"random"}}); err == nil {
+		t.Error("Expected error for invalid sort")
+	}
+}
+
+func TestParseRSSLatin1(t *testing.T) {
+	// "Café" and "déjà vu" encoded as ISO-8859-1
+	feed := "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n" +
+		"<rss version=\"2.0\"><channel><title>Caf\xe9</title>" +
+		"<item><title>Menu</title><description>Une impression de d\xe9j\xe0 vu</description><guid>menu</guid></item>" +
+		"</channel></rss>"
+
+	rss, err := parseRSS([]byte(feed))
+	if err != nil {
+		t.Fatalf("Failed to parse ISO-8859-1 feed: %v", err)
+	}
+	if rss.Channel.Title != "Café" {
+		t.Errorf("Expected title 'Café', got '%s'", rss.Channel.Title)
+	}
+
+	ical, err := rssToICal(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+	if !utf8.ValidString(ical) {
+		t.Error("Expected iCal output to be valid UTF-8")
+	}
+	if !strings.Contains(ical, "DESCRIPTION:Une impression de déjà vu") {
+		t.Errorf("Expected accented description to survive, got: %s", ical)
+	}
+}
+
+func TestParseRSSWindows1252(t *testing.T) {
+	// Curly quotes are 0x93/0x94 in Windows-1252
+	feed := "<?xml version=\"1.0\" encoding=\"windows-1252\"?>\n" +
+		"<rss version=\"2.0\"><channel><title>\x93Quoted\x94</title></channel></rss>"
+
+	rss, err := parseRSS([]byte(feed))
+	if err != nil {
+		t.Fatalf("Failed to parse Windows-1252 feed: %v", err)
+	}
+	if rss.Channel.Title != "“Quoted”" {
+		t.Errorf("Expected curly quotes, got '%s'", rss.Channel.Title)
+	}
+}
+
+// largeFeed builds a feed with n items for streaming tests and benchmarks.
+func largeFeed(n int) *RSS {
+	rss := &RSS{Channel: Channel{Title: "Large Feed", Description: "Many items"}}
+	for i := 0; i < n; i++ {
+		rss.Channel.Items = append(rss.Channel.Items, Item{
+			Title:       fmt.Sprintf("Item %d", i),
+			Description: fmt.Sprintf("<p>Description for item %d, with some <b>HTML</b></p>", i),
+			Link:        fmt.Sprintf("https://example.com/items/%d", i),
+			PubDate:     time.Date(2025, 7, 27, 12, 0, 0, 0, time.UTC).Add(time.Duration(i) * time.Minute).Format(time.RFC1123Z),
+			GUID:        fmt.Sprintf("item-%d", i),
+			Categories:  []string{"News", "Updates"},
+		})
+	}
+	return rss
+}
+
+func TestCalendarHandlerStreamsSameCalendar(t *testing.T) {
+	rss := largeFeed(50)
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		xml.NewEncoder(w).Encode(rss)
+	}))
+	defer mockServer.Close()
+
+	// Clear cache for clean test
+	cache = &Cache{}
+
+	req := httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil)
+	w := httptest.NewRecorder()
+	calendarHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", w.Code)
+	}
+
+	expected, err := rssToICal(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+
+	// DTSTAMP is the conversion time, so compare with it removed
+	stripStamps := func(ical string) string {
+		var lines []string
+		for _, line := range strings.Split(ical, "\r\n") {
+			if !strings.HasPrefix(line, "DTSTAMP:") {
+				lines = append(lines, line)
+			}
+		}
+		return strings.Join(lines, "\r\n")
+	}
+
+	streamed := w.Body.String()
+	if stripStamps(streamed) != stripStamps(expected) {
+		t.Errorf("Streamed calendar differs from serialized calendar:\n%s\n---\n%s", streamed, expected)
+	}
+	if cached, ok := cache.Get(mockServer.URL); !ok || cached != streamed {
+		t.Error("Expected the streamed calendar to be cached")
+	}
+}
+
+func BenchmarkRSSToICal(b *testing.B) {
+	rss := largeFeed(10000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := rssToICal(rss, defaultCalendarOptions()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStreamCalendar(b *testing.B) {
+	rss := largeFeed(10000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		conv, err := prepareCalendar(context.Background(), rss, defaultCalendarOptions(), nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := conv.writeTo(io.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestRSSToICalRefreshInterval(t *testing.T) {
+	defer func(ttl time.Duration) { cacheTTL = ttl }(cacheTTL)
+	cacheTTL = 5 * time.Minute
+
+	ical, err := rssToICal(&RSS{Channel: Channel{Title: "Feed"}}, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+	for _, exp := range []string{"REFRESH-INTERVAL;VALUE=DURATION:PT5M", "X-PUBLISHED-TTL:PT5M"} {
+		if !strings.Contains(ical, exp) {
+			t.Errorf("Expected iCal to contain '%s', got: %s", exp, ical)
+		}
+	}
+
+	// A feed's own <ttl> is the effective cache lifetime
+	ical, err = rssToICal(&RSS{Channel: Channel{Title: "Feed", TTL: 90}}, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+	if !strings.Contains(ical, "REFRESH-INTERVAL;VALUE=DURATION:PT1H30M") {
+		t.Errorf("Expected refresh interval from feed TTL, got: %s", ical)
+	}
+}
+
+func TestFetchFeedCancelled(t *testing.T) {
+	release := make(chan struct{})
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer mockServer.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := fetchRSS(ctx, mockServer.URL)
+	if err == nil {
+		t.Fatal("Expected error from cancelled fetch")
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected cancelled fetch to return promptly, took %v", elapsed)
+	}
+}
+
+func TestFetchRSSBodyLimit(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(`<?xml version="1.0"?><rss version="2.0"><channel><title>`))
+		// Keep streaming well past the limit
+		chunk := []byte(strings.Repeat("x", 1024))
+		for i := 0; i < 64; i++ {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+		}
+		w.Write([]byte(`</title></channel></rss>`))
+	}))
+	defer mockServer.Close()
+
+	defer func(n int) { maxFeedBytes = n }(maxFeedBytes)
+	maxFeedBytes = 16 << 10
+
+	_, err := fetchRSS(context.Background(), mockServer.URL)
+	if err == nil || !strings.Contains(err.Error(), "exceeds the 16384 byte limit") {
+		t.Errorf("Expected size limit error, got: %v", err)
+	}
+
+	maxFeedBytes = 1 << 20
+	if _, err := fetchRSS(context.Background(), mockServer.URL); err != nil {
+		t.Errorf("Expected feed under the limit to parse, got: %v", err)
+	}
+}
+
+func TestFetchRSSBasicAuth(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "releases" || password != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(mockRSSFeed))
+	}))
+	defer mockServer.Close()
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	authURL := strings.Replace(mockServer.URL, "http://", "http://releases:s3cret@", 1)
+	if _, err := fetchRSS(context.Background(), authURL); err != nil {
+		t.Errorf("Expected authenticated fetch to succeed, got: %v", err)
+	}
+	if strings.Contains(logs.String(), "s3cret") {
+		t.Errorf("Expected password to be redacted from logs, got: %s", logs.String())
+	}
+
+	if _, err := fetchRSS(context.Background(), mockServer.URL); err == nil {
+		t.Error("Expected unauthenticated fetch to fail")
+	}
+}
+
+func TestCalendarHandlerIfModifiedSince(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(mockRSSFeed))
+	}))
+	defer mockServer.Close()
+
+	// Clear cache for clean test
+	cache = &Cache{}
+	failures = &NegativeCache{}
+
+	req := httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil)
+	w := httptest.NewRecorder()
+	calendarHandler(w, req)
+
+	lastModified := w.Header().Get("Last-Modified")
+	if _, err := http.ParseTime(lastModified); err != nil {
+		t.Fatalf("Expected a Last-Modified date, got '%s'", lastModified)
+	}
+
+	req = httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil)
+	req.Header.Set("If-Modified-Since", lastModified)
+	w = httptest.NewRecorder()
+	calendarHandler(w, req)
+
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected status code 304, got %d", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected empty body on 304, got: %s", w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil)
+	req.Header.Set("If-Modified-Since", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+	w = httptest.NewRecorder()
+	calendarHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code 200 for an older copy, got %d", w.Code)
+	}
+}
+
+func TestCalendarHandlerETag(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(mockRSSFeed))
+	}))
+	defer mockServer.Close()
+
+	// Clear cache for clean test
+	cache = &Cache{}
+	failures = &NegativeCache{}
+
+	req := httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil)
+	w := httptest.NewRecorder()
+	calendarHandler(w, req)
+
+	// The fresh calendar is streamed, so its ETag is only known afterwards
+	if got := w.Header().Get("ETag"); got != "" {
+		t.Errorf("Expected no ETag on a streamed calendar, got '%s'", got)
+	}
+	etag := calendarETag(w.Body.String())
+	if cached, ok := cache.Lookup(cacheKey(url.Values{"url": {mockServer.URL}})); !ok || cached.etag != etag {
+		t.Fatalf("Expected the cached ETag of the calendar body %s, got %+v", etag, cached.etag)
+	}
+
+	// A cache hit carries it
+	req = httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil)
+	w = httptest.NewRecorder()
+	calendarHandler(w, req)
+	if got := w.Header().Get("ETag"); got != etag {
+		t.Errorf("Expected stable ETag %s, got %s", etag, got)
+	}
+
+	req = httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil)
+	req.Header.Set("If-None-Match", `"stale", `+etag)
+	w = httptest.NewRecorder()
+	calendarHandler(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected status code 304, got %d", w.Code)
+	}
+
+	// If-None-Match wins over an If-Modified-Since that would match
+	req = httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil)
+	req.Header.Set("If-None-Match", `"stale"`)
+	req.Header.Set("If-Modified-Since", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	w = httptest.NewRecorder()
+	calendarHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code 200 for a different ETag, got %d", w.Code)
+	}
+}
+
+func TestRSSToICalContentEncoded(t *testing.T) {
+	feed := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/">
+  <channel>
+    <title>Blog</title>
+    <item>
+      <title>Long Post</title>
+      <description>Short summary</description>
+      <content:encoded><![CDATA[<p>The full article body with every paragraph</p>]]></content:encoded>
+      <guid>long-post</guid>
+    </item>
+    <item>
+      <title>Summary Only</title>
+      <description>Just a summary</description>
+      <guid>summary-only</guid>
+    </item>
+  </channel>
+</rss>`
+
+	rss, err := parseRSS([]byte(feed))
+	if err != nil {
+		t.Fatalf("Failed to parse feed: %v", err)
+	}
+
+	opts, _ := parseCalendarOptions(url.Values{"desc": {"full"}})
+	ical, err := rssToICal(rss, opts)
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+	if !strings.Contains(ical, "DESCRIPTION:The full article body with every paragraph") {
+		t.Errorf("Expected full content as plain text DESCRIPTION, got: %s", ical)
+	}
+	if strings.Contains(ical, "Short summary") {
+		t.Errorf("Expected summary to be replaced by full content, got: %s", ical)
+	}
+	if !strings.Contains(ical, "DESCRIPTION:Just a summary") {
+		t.Errorf("Expected items without content:encoded to keep their description, got: %s", ical)
+	}
+
+	opts, _ = parseCalendarOptions(url.Values{"desc": {"summary"}})
+	ical, _ = rssToICal(rss, opts)
+	if !strings.Contains(ical, "DESCRIPTION:Short summary") {
+		t.Errorf("Expected desc=summary to use the description, got: %s", ical)
+	}
+}
+
+func TestRSSToICalMethod(t *testing.T) {
+	rss := &RSS{}
+	if err := parseRSSFromString(mockRSSFeed, rss); err != nil {
+		t.Fatalf("Failed to parse mock RSS: %v", err)
+	}
+
+	tests := []struct {
+		method   string
+		expected string
+	}{
+		{"", "METHOD:PUBLISH"},
+		{"publish", "METHOD:PUBLISH"},
+		{"request", "METHOD:REQUEST"},
+		{"none", ""},
+	}
+
+	for _, test := range tests {
+		opts, err := parseCalendarOptions(url.Values{"method": {test.method}})
+		if err != nil {
+			t.Fatalf("Unexpected error for method %q: %v", test.method, err)
+		}
+		ical, err := rssToICal(rss, opts)
+		if err != nil {
+			t.Fatalf("Failed to convert RSS to iCal: %v", err)
+		}
+		if test.expected == "" {
+			if strings.Contains(ical, "METHOD:") {
+				t.Errorf("Expected no METHOD for method=none, got: %s", ical)
+			}
+		} else if !strings.Contains(ical, test.expected+"\r\n") {
+			t.Errorf("Expected %s for method=%q, got: %s", test.expected, test.method, ical)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/calendar?url=https://test.com/rss.xml&method=cancel", nil)
+	w := httptest.NewRecorder()
+	calendarHandler(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code 400 for unknown method, got %d", w.Code)
+	}
+}
+
+func TestRSSToICalUntitledItems(t *testing.T) {
+	feed := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+  <channel>
+    <title>Microblog</title>
+    <item>
+      <description><![CDATA[<p>Just shipped the new release of our calendar converter, with support for untitled posts and much more besides</p>]]></description>
+      <guid>long-note</guid>
+    </item>
+    <item>
+      <description>Short note</description>
+      <guid>short-note</guid>
+    </item>
+    <item>
+      <link>https://example.com/photo/1</link>
+      <guid>photo</guid>
+    </item>
+    <item>
+      <guid>empty</guid>
+    </item>
+  </channel>
+</rss>`
+
+	rss, err := parseRSS([]byte(feed))
+	if err != nil {
+		t.Fatalf("Failed to parse feed: %v", err)
+	}
+
+	ical, err := rssToICal(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+	for _, summary := range []string{
+		`SUMMARY:Just shipped the new release of our calendar converter\, with support for…`,
+		"SUMMARY:Short note",
+		"SUMMARY:https://example.com/photo/1",
+		"SUMMARY:(untitled)",
+	} {
+		if !strings.Contains(unfoldICal(ical), summary+"\r\n") {
+			t.Errorf("Expected %s, got: %s", summary, ical)
+		}
+	}
+}
+
+func TestTruncateWords(t *testing.T) {
+	tests := []struct {
+		text     string
+		limit    int
+		expected string
+	}{
+		{"short enough", 20, "short enough"},
+		{"one two three four", 10, "one two…"},
+		{"unbreakablewordhere", 10, "unbreakabl…"},
+		{"naïve café crème brûlée", 12, "naïve café…"},
+	}
+
+	for _, test := range tests {
+		if got := truncateWords(test.text, test.limit); got != test.expected {
+			t.Errorf("truncateWords(%q, %d) = %q, expected %q", test.text, test.limit, got, test.expected)
+		}
+	}
+}
+
+// unfoldICal joins folded content lines back together.
+func unfoldICal(ical string) string {
+	return strings.ReplaceAll(ical, "\r\n ", "")
+}
+
+func TestScheduleItemsWarnsOnBadDates(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	items := []Item{
+		{Title: "Dated", GUID: "dated", PubDate: "Mon, 27 Jul 2025 12:00:00 GMT"},
+		{Title: "Garbled", GUID: "garbled", PubDate: "sometime last week"},
+		{Title: "Garbled too", GUID: "garbled-too", PubDate: "27.07.2025"},
+		{Title: "No date", GUID: "no-date"},
+	}
+	scheduled := scheduleItems(items, defaultCalendarOptions())
+
+	if len(scheduled) != len(items) {
+		t.Fatalf("Expected items with bad dates to be kept, got %d", len(scheduled))
+	}
+	for _, item := range scheduled[1:] {
+		if item.dated || time.Since(item.startTime) > time.Minute {
+			t.Errorf("Expected %s to fall back to the conversion time, got %v", item.GUID, item.startTime)
+		}
+	}
+
+	if count := strings.Count(logs.String(), "unrecognized item dates"); count != 1 {
+		t.Errorf("Expected a single warning, got %d: %s", count, logs.String())
+	}
+	if !strings.Contains(logs.String(), "items=2") {
+		t.Errorf("Expected the warning to count both bad dates, got: %s", logs.String())
+	}
+}
+
+func TestRSSToICalItemUpdated(t *testing.T) {
+	feed := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">
+  <channel>
+    <title>Edits</title>
+    <item>
+      <title>Edited</title>
+      <pubDate>Mon, 27 Jul 2025 12:00:00 GMT</pubDate>
+      <atom:updated>2025-07-29T08:30:00Z</atom:updated>
+      <guid>edited</guid>
+    </item>
+    <item>
+      <title>Rebuilt</title>
+      <pubDate>Tue, 28 Jul 2025 12:00:00 GMT</pubDate>
+      <lastBuildDate>Wed, 29 Jul 2025 12:00:00 GMT</lastBuildDate>
+      <guid>rebuilt</guid>
+    </item>
+    <item>
+      <title>Backdated update</title>
+      <pubDate>Wed, 29 Jul 2025 12:00:00 GMT</pubDate>
+      <atom:updated>2025-07-01T00:00:00Z</atom:updated>
+      <guid>backdated</guid>
+    </item>
+  </channel>
+</rss>`
+
+	rss, err := parseRSS([]byte(feed))
+	if err != nil {
+		t.Fatalf("Failed to parse feed: %v", err)
+	}
+	ical, err := rssToICal(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+
+	events := strings.Split(ical, "BEGIN:VEVENT")[1:]
+	expected := [][2]string{
+		{"CREATED:20250727T120000Z", "LAST-MODIFIED:20250729T083000Z"},
+		{"CREATED:20250728T120000Z", "LAST-MODIFIED:20250729T120000Z"},
+		// An update older than the publish date is ignored
+		{"CREATED:20250729T120000Z", "LAST-MODIFIED:20250729T120000Z"},
+	}
+	for i, want := range expected {
+		if !strings.Contains(events[i], want[0]) || !strings.Contains(events[i], want[1]) {
+			t.Errorf("Expected %s and %s, got: %s", want[0], want[1], events[i])
+		}
+	}
+}
+
+func TestRSSToICalUIDPrefix(t *testing.T) {
+	rss := &RSS{}
+	if err := parseRSSFromString(mockRSSFeed, rss); err != nil {
+		t.Fatalf("Failed to parse mock RSS: %v", err)
+	}
+	// An item without a GUID gets a derived UID, which is prefixed too
+	rss.Channel.Items = append(rss.Channel.Items, Item{Title: "No GUID", Link: "https://example.com/3"})
+
+	opts, err := parseCalendarOptions(url.Values{"uid_prefix": {"work-"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ical, err := rssToICal(rss, opts)
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+
+	uids := 0
+	for _, line := range strings.Split(ical, "\r\n") {
+		if uid, ok := strings.CutPrefix(line, "UID:"); ok {
+			uids++
+			if !strings.HasPrefix(uid, "work-") {
+				t.Errorf("Expected UID with prefix work-, got %s", uid)
+			}
+		}
+	}
+	if uids != 3 {
+		t.Errorf("Expected 3 UIDs, got %d", uids)
+	}
+	if !strings.Contains(ical, "UID:work-test-guid-1\r\n") {
+		t.Errorf("Expected prefixed GUID, got: %s", ical)
+	}
+
+	// The same item keeps the same UID across conversions
+	again, _ := rssToICal(rss, opts)
+	derived := regexp.MustCompile(`UID:work-[0-9a-f]{40}`)
+	if first := derived.FindString(ical); first == "" || first != derived.FindString(again) {
+		t.Errorf("Expected a stable derived UID, got %q and %q", first, derived.FindString(again))
+	}
+
+	if _, err := parseCalendarOptions(url.Values{"uid_prefix": {"a b"}}); err == nil {
+		t.Error("Expected error for invalid uid_prefix")
+	}
+}
+
+func TestCalendarHandlerHead(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(mockRSSFeed))
+	}))
+	defer mockServer.Close()
+
+	// Clear cache for clean test
+	cache = &Cache{}
+	failures = &NegativeCache{}
+
+	for _, encoding := range []string{"", "gzip"} {
+		req := httptest.NewRequest("HEAD", "/calendar?url="+mockServer.URL, nil)
+		req.Header.Set("Accept-Encoding", encoding)
+		w := httptest.NewRecorder()
+		calendarHandler(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status code 200, got %d", w.Code)
+		}
+		if w.Body.Len() != 0 {
+			t.Errorf("Expected empty body for HEAD, got %d bytes", w.Body.Len())
+		}
+		if contentType := w.Header().Get("Content-Type"); contentType != "text/calendar; charset=utf-8" {
+			t.Errorf("Expected Content-Type 'text/calendar; charset=utf-8', got '%s'", contentType)
+		}
+
+		// The headers match what a GET would send
+		get := httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil)
+		get.Header.Set("Accept-Encoding", encoding)
+		getW := httptest.NewRecorder()
+		calendarHandler(getW, get)
+		// Compressed bodies are streamed, so only uncompressed ones have a length
+		expectedLength := strconv.Itoa(getW.Body.Len())
+		if encoding == "gzip" {
+			expectedLength = ""
+		}
+		if length := w.Header().Get("Content-Length"); length != expectedLength || getW.Header().Get("Content-Length") != expectedLength {
+			t.Errorf("Expected Content-Length %q with encoding %q, got '%s'", expectedLength, encoding, length)
+		}
+		if w.Header().Get("ETag") != getW.Header().Get("ETag") {
+			t.Errorf("Expected the same ETag for HEAD and GET")
+		}
+	}
+}
+
+func TestRSSToICalProductIDAndName(t *testing.T) {
+	rss := &RSS{}
+	if err := parseRSSFromString(mockRSSFeed, rss); err != nil {
+		t.Fatalf("Failed to parse mock RSS: %v", err)
+	}
+
+	opts, err := parseCalendarOptions(url.Values{
+		"prodid": {"-//Acme Corp//Team Events//EN"},
+		"name":   {"Team Events, Berlin"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ical, err := rssToICal(rss, opts)
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+
+	for _, want := range []string{
+		"PRODID:-//Acme Corp//Team Events//EN\r\n",
+		"NAME:Team Events\\, Berlin\r\n",
+		"X-WR-CALNAME:Team Events\\, Berlin\r\n",
+	} {
+		if !strings.Contains(ical, want) {
+			t.Errorf("Expected %q in output, got: %s", want, ical)
+		}
+	}
+	if strings.Contains(ical, "Test RSS Feed") || strings.Contains(ical, "RSS2ICal") {
+		t.Errorf("Expected the defaults to be replaced, got: %s", ical)
+	}
+
+	for _, query := range []url.Values{
+		{"prodid": {strings.Repeat("x", maxProductIDLength+1)}},
+		{"name": {strings.Repeat("x", maxCalendarNameLength+1)}},
+		{"name": {"Team\r\nX-INJECTED:1"}},
+	} {
+		if _, err := parseCalendarOptions(query); err == nil {
+			t.Errorf("Expected an error for %v", query)
+		}
+	}
+}
+
+func TestFetchRSSUserAgent(t *testing.T) {
+	var got string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("User-Agent")
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(mockRSSFeed))
+	}))
+	defer mockServer.Close()
+
+	defer func(agent string) { userAgent = agent }(userAgent)
+	userAgent = "ExampleCalendarBot/2.0 (+https://calendar.example.com/bot)"
+
+	if _, err := fetchRSS(context.Background(), mockServer.URL); err != nil {
+		t.Fatalf("Failed to fetch RSS: %v", err)
+	}
+	if got != userAgent {
+		t.Errorf("Expected User-Agent %q upstream, got %q", userAgent, got)
+	}
+}
+
+func TestDefaultUserAgentVersion(t *testing.T) {
+	defer func(v string) { version = v }(version)
+	version = "1.4.2"
+	if got := defaultUserAgent(); got != "RSS2ICal/1.4.2 (Go HTTP Client)" {
+		t.Errorf("Expected the build version in the User-Agent, got %q", got)
+	}
+}
+
+func TestParseRSSCDATATitles(t *testing.T) {
+	feed := `<?xml version="1.0"?>
+<rss version="2.0"><channel>
+<title><![CDATA[
+    Events &amp; Meetups
+]]></title>
+<item>
+  <title><![CDATA[
+      Tom &amp; Jerry&#8217;s <b>Live</b> Show
+  ]]></title>
+  <description><![CDATA[
+    <p>Doors at <em>7pm</em> &amp; show at 8pm</p>
+  ]]></description>
+  <guid>show-1</guid>
+  <pubDate>Mon, 01 Jan 2024 19:00:00 GMT</pubDate>
+</item>
+<item>
+  <title>Double &amp;amp; escaped</title>
+  <guid>show-2</guid>
+  <pubDate>Tue, 02 Jan 2024 19:00:00 GMT</pubDate>
+</item>
+</channel></rss>`
+
+	rss, err := parseRSS([]byte(feed))
+	if err != nil {
+		t.Fatalf("Failed to parse RSS: %v", err)
+	}
+	if rss.Channel.Title != "Events & Meetups" {
+		t.Errorf("Expected a clean channel title, got %q", rss.Channel.Title)
+	}
+	item := rss.Channel.Items[0]
+	if item.Title != "Tom & Jerry’s Live Show" {
+		t.Errorf("Expected a clean title, got %q", item.Title)
+	}
+	// The description's CDATA markup is kept for X-ALT-DESC and html=raw
+	if item.Description != "<p>Doors at <em>7pm</em> &amp; show at 8pm</p>" {
+		t.Errorf("Expected the description kept verbatim but trimmed, got %q", item.Description)
+	}
+	if got := rss.Channel.Items[1].Title; got != "Double & escaped" {
+		t.Errorf("Expected double-escaped entities decoded, got %q", got)
+	}
+
+	ical, err := rssToICal(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+	ical = unfoldICal(ical)
+	for _, want := range []string{
+		"SUMMARY:Tom & Jerry’s Live Show\r\n",
+		"DESCRIPTION:Doors at 7pm & show at 8pm\r\n",
+	} {
+		if !strings.Contains(ical, want) {
+			t.Errorf("Expected %q in output, got: %s", want, ical)
+		}
+	}
+}
+
+func TestRSSToICalTransparency(t *testing.T) {
+	rss := &RSS{}
+	if err := parseRSSFromString(mockRSSFeed, rss); err != nil {
+		t.Fatalf("Failed to parse mock RSS: %v", err)
+	}
+
+	tests := []struct {
+		query url.Values
+		want  string
+	}{
+		{url.Values{}, "TRANSP:TRANSPARENT"},
+		{url.Values{"busy": {"false"}}, "TRANSP:TRANSPARENT"},
+		{url.Values{"busy": {"true"}}, "TRANSP:OPAQUE"},
+	}
+	for _, tt := range tests {
+		opts, err := parseCalendarOptions(tt.query)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		ical, err := rssToICal(rss, opts)
+		if err != nil {
+			t.Fatalf("Failed to convert RSS to iCal: %v", err)
+		}
+		if n := strings.Count(ical, tt.want+"\r\n"); n != 2 {
+			t.Errorf("%v: expected %s on both events, found %d", tt.query, tt.want, n)
+		}
+	}
+
+	if _, err := parseCalendarOptions(url.Values{"busy": {"maybe"}}); err == nil {
+		t.Error("Expected an error for an invalid busy value")
+	}
+}
+
+func TestRSSToICalStatus(t *testing.T) {
+	rss := &RSS{}
+	if err := parseRSSFromString(mockRSSFeed, rss); err != nil {
+		t.Fatalf("Failed to parse mock RSS: %v", err)
+	}
+
+	for raw, want := range map[string]string{
+		"":          "STATUS:CONFIRMED",
+		"confirmed": "STATUS:CONFIRMED",
+		"tentative": "STATUS:TENTATIVE",
+		"cancelled": "STATUS:CANCELLED",
+	} {
+		opts, err := parseCalendarOptions(url.Values{"status": {raw}})
+		if err != nil {
+			t.Fatalf("Unexpected error for status %q: %v", raw, err)
+		}
+		ical, err := rssToICal(rss, opts)
+		if err != nil {
+			t.Fatalf("Failed to convert RSS to iCal: %v", err)
+		}
+		if n := strings.Count(ical, "\r\nSTATUS:"); n != 2 {
+			t.Errorf("status %q: expected 2 STATUS lines, got %d", raw, n)
+		}
+		if n := strings.Count(ical, "\r\n"+want+"\r\n"); n != 2 {
+			t.Errorf("status %q: expected %s on both events, got: %s", raw, want, ical)
+		}
+	}
+
+	if _, err := parseCalendarOptions(url.Values{"status": {"maybe"}}); err == nil {
+		t.Error("Expected an error for an invalid status")
+	}
+}
+
+func TestRSSToICalTitleEntities(t *testing.T) {
+	feed := `<?xml version="1.0"?>
+<rss version="2.0"><channel><title>Feed</title>
+<item>
+  <title>Q&amp;amp;A: Tom&amp;#8217;s &amp;quot;Ideas&amp;quot;, Part 1; C:\Path</title>
+  <description>&lt;p&gt;Body&lt;/p&gt;</description>
+  <guid>item-1</guid>
+  <pubDate>Mon, 01 Jan 2024 12:00:00 GMT</pubDate>
+</item>
+</channel></rss>`
+
+	rss, err := parseRSS([]byte(feed))
+	if err != nil {
+		t.Fatalf("Failed to parse RSS: %v", err)
+	}
+	if want := `Q&A: Tom’s "Ideas", Part 1; C:\Path`; rss.Channel.Items[0].Title != want {
+		t.Errorf("Expected title %q, got %q", want, rss.Channel.Items[0].Title)
+	}
+
+	// Titles are decoded even when descriptions keep their HTML
+	opts, err := parseCalendarOptions(url.Values{"html": {"raw"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ical, err := rssToICal(rss, opts)
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+	ical = unfoldICal(ical)
+	for _, want := range []string{
+		`SUMMARY:Q&A: Tom’s "Ideas"\, Part 1\; C:\\Path` + "\r\n",
+		"DESCRIPTION:<p>Body</p>\r\n",
+	} {
+		if !strings.Contains(ical, want) {
+			t.Errorf("Expected %q in output, got: %s", want, ical)
+		}
+	}
+}
+
+func TestFetchRSSMaxItems(t *testing.T) {
+	var feed strings.Builder
+	feed.WriteString(`<?xml version="1.0"?><rss version="2.0"><channel><title>Huge</title>`)
+	for i := 0; i < 25; i++ {
+		fmt.Fprintf(&feed, "<item><title>Item %d</title><guid>item-%d</guid><pubDate>Mon, 01 Jan 2024 12:00:00 GMT</pubDate></item>", i, i)
+	}
+	feed.WriteString(`</channel></rss>`)
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(feed.String()))
+	}))
+	defer mockServer.Close()
+
+	defer func(n int) { maxItems = n }(maxItems)
+	maxItems = 10
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	rss, err := fetchRSS(context.Background(), mockServer.URL)
+	if err != nil {
+		t.Fatalf("Failed to fetch RSS: %v", err)
+	}
+	if n := len(rss.Channel.Items); n != 10 {
+		t.Errorf("Expected 10 items after truncation, got %d", n)
+	}
+	if rss.Channel.Items[9].Title != "Item 9" {
+		t.Errorf("Expected the first items to be kept, got %q last", rss.Channel.Items[9].Title)
+	}
+	if !strings.Contains(logs.String(), "too many items") || !strings.Contains(logs.String(), "items=25") {
+		t.Errorf("Expected a truncation warning, got: %s", logs.String())
+	}
+
+	ical, err := rssToICal(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+	if n := strings.Count(ical, "BEGIN:VEVENT"); n != 10 {
+		t.Errorf("Expected 10 events, got %d", n)
+	}
+}
+
+func TestRenderCalendarUnfolded(t *testing.T) {
+	description := strings.Repeat("A long description that wraps. ", 10)
+	rss := &RSS{Channel: Channel{Title: "Feed", Items: []Item{{
+		Title:       "Item",
+		GUID:        "item-1",
+		PubDate:     "Mon, 01 Jan 2024 12:00:00 GMT",
+		Description: description,
+	}}}}
+
+	rendered, err := renderCalendar(context.Background(), rss, defaultCalendarOptions(), nil)
+	if err != nil {
+		t.Fatalf("Failed to render calendar: %v", err)
+	}
+	strict := rendered.data
+	if !strings.Contains(strict, "\r\n ") {
+		t.Error("Expected the long description folded by default")
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(strict, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("Expected lines of at most 75 octets, got %d: %q", len(line), line)
+		}
+	}
+
+	opts, err := parseCalendarOptions(url.Values{"strict": {"false"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rendered, err = renderCalendar(context.Background(), rss, opts, nil)
+	if err != nil {
+		t.Fatalf("Failed to render calendar: %v", err)
+	}
+	relaxed := rendered.data
+	if strings.Contains(relaxed, "\r") {
+		t.Error("Expected LF line endings without CR")
+	}
+	if !strings.Contains(relaxed, "\nDESCRIPTION:"+strings.TrimSpace(description)+"\n") {
+		t.Errorf("Expected the description on one line, got:\n%s", relaxed)
+	}
+	if relaxed != strings.ReplaceAll(unfoldICal(strict), "\r\n", "\n") {
+		t.Error("Expected the relaxed calendar to match the strict one unfolded")
+	}
+
+	if _, err := parseCalendarOptions(url.Values{"strict": {"sometimes"}}); err == nil {
+		t.Error("Expected an error for an invalid strict value")
+	}
+}
+
+func TestHomeHandlerWebcalLink(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	homeHandler(w, req)
+
+	body := w.Body.String()
+	for _, element := range []string{`id="webcalLink"`, "'webcal://' + window.location.host + '/calendar?url=' + encodedUrl"} {
+		if !strings.Contains(body, element) {
+			t.Errorf("Expected HTML to contain '%s', but it was not found", element)
+		}
+	}
+}
+
+func TestHomeHandlerUnknownPath(t *testing.T) {
+	req := httptest.NewRequest("GET", "/nope", nil)
+	w := httptest.NewRecorder()
+	homeHandler(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown path, got %d", w.Code)
+	}
+}
+
+func TestCalendarHandlerSkipsBadItems(t *testing.T) {
+	feed := `<?xml version="1.0"?><rss version="2.0"><channel><title>Feed</title>
+<item><title>First</title><guid>first</guid><pubDate>Mon, 01 Jan 2024 12:00:00 GMT</pubDate></item>
+<item><title>Broken</title><guid>broken</guid><pubDate>Tue, 02 Jan 2024 12:00:00 GMT</pubDate>
+<enclosure url="https://example.com/a.mp3&#13;&#10;X-INJECTED:1" type="audio/mpeg"/></item>
+<item><title>Last</title><guid>last</guid><pubDate>Wed, 03 Jan 2024 12:00:00 GMT</pubDate></item>
+</channel></rss>`
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(feed))
+	}))
+	defer mockServer.Close()
+
+	cache = &Cache{}
+	failures = &NegativeCache{}
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	for _, cached := range []bool{false, true} {
+		req := httptest.NewRequest("GET", "/calendar?url="+url.QueryEscape(mockServer.URL), nil)
+		w := httptest.NewRecorder()
+		calendarHandler(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if got := w.Header().Get("X-Skipped-Items"); got != "1" {
+			t.Errorf("cached=%v: expected X-Skipped-Items: 1, got %q", cached, got)
+		}
+		body := w.Body.String()
+		if !strings.Contains(body, "SUMMARY:First") || !strings.Contains(body, "SUMMARY:Last") {
+			t.Errorf("cached=%v: expected the good items converted, got:\n%s", cached, body)
+		}
+		if strings.Contains(body, "Broken") || strings.Contains(body, "X-INJECTED") {
+			t.Errorf("cached=%v: expected the bad item left out entirely, got:\n%s", cached, body)
+		}
+	}
+	if !strings.Contains(logs.String(), "skipping item") || !strings.Contains(logs.String(), "uid=broken") {
+		t.Errorf("Expected a warning naming the skipped item, got: %s", logs.String())
+	}
+}
+
+func TestCalendarHandlerNoSkippedItemsHeader(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(mockRSSFeed))
+	}))
+	defer mockServer.Close()
+
+	cache = &Cache{}
+	failures = &NegativeCache{}
+
+	req := httptest.NewRequest("GET", "/calendar?url="+url.QueryEscape(mockServer.URL), nil)
+	w := httptest.NewRecorder()
+	calendarHandler(w, req)
+
+	if got := w.Header().Get("X-Skipped-Items"); got != "" {
+		t.Errorf("Expected no X-Skipped-Items header, got %q", got)
+	}
+}
+
+func TestRSSToICalOrganizer(t *testing.T) {
+	feed := `<?xml version="1.0"?><rss version="2.0"><channel><title>Feed</title>
+<managingEditor>editor@example.com (Jane Doe)</managingEditor>
+<item><title>Edited</title><guid>edited</guid><pubDate>Mon, 01 Jan 2024 12:00:00 GMT</pubDate></item>
+<item><title>Written</title><guid>written</guid><pubDate>Tue, 02 Jan 2024 12:00:00 GMT</pubDate><author>writer@example.com (Sam Writer)</author></item>
+<item><title>Named</title><guid>named</guid><pubDate>Wed, 03 Jan 2024 12:00:00 GMT</pubDate><author>Doe, Jane &lt;jane@example.com&gt;</author></item>
+</channel></rss>`
+	rss := &RSS{}
+	if err := parseRSSFromString(feed, rss); err != nil {
+		t.Fatalf("Failed to parse RSS: %v", err)
+	}
+	ical, err := rssToICal(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+	ical = unfoldICal(ical)
+
+	for _, want := range []string{
+		"ORGANIZER;CN=Jane Doe:mailto:editor@example.com\r\n",
+		"ORGANIZER;CN=Sam Writer:mailto:writer@example.com\r\n",
+	} {
+		if !strings.Contains(ical, want) {
+			t.Errorf("Expected %q in:\n%s", want, ical)
+		}
+	}
+	// "Doe, Jane" can't be written as a CN without quoting
+	if !strings.Contains(ical, "ORGANIZER:mailto:jane@example.com\r\n") {
+		t.Errorf("Expected an ORGANIZER without CN for a name with a comma, got:\n%s", ical)
+	}
+
+	rss = &RSS{}
+	if err := parseRSSFromString(mockRSSFeed, rss); err != nil {
+		t.Fatalf("Failed to parse mock RSS: %v", err)
+	}
+	if ical, _ := rssToICal(rss, defaultCalendarOptions()); strings.Contains(ical, "ORGANIZER") {
+		t.Error("Expected no ORGANIZER for a feed without author emails")
+	}
+}
+
+func TestRSSToICalContact(t *testing.T) {
+	feed := `<?xml version="1.0"?><rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/"><channel><title>Blog</title>
+<item><title>Created</title><guid>created</guid><pubDate>Mon, 01 Jan 2024 12:00:00 GMT</pubDate><dc:creator><![CDATA[Alex Smith]]></dc:creator></item>
+<item><title>Authored</title><guid>authored</guid><pubDate>Tue, 02 Jan 2024 12:00:00 GMT</pubDate><author>sam@example.com (Sam Writer)</author></item>
+<item><title>Anonymous</title><guid>anonymous</guid><pubDate>Wed, 03 Jan 2024 12:00:00 GMT</pubDate></item>
+</channel></rss>`
+	rss := &RSS{}
+	if err := parseRSSFromString(feed, rss); err != nil {
+		t.Fatalf("Failed to parse RSS: %v", err)
+	}
+	ical, err := rssToICal(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+	ical = unfoldICal(ical)
+
+	for _, want := range []string{"CONTACT:Alex Smith\r\n", "CONTACT:Sam Writer <sam@example.com>\r\n"} {
+		if !strings.Contains(ical, want) {
+			t.Errorf("Expected %q in:\n%s", want, ical)
+		}
+	}
+	if n := strings.Count(ical, "CONTACT:"); n != 2 {
+		t.Errorf("Expected CONTACT only on items with an author, got %d", n)
+	}
+
+	data, err := rssToJSON(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to JSON: %v", err)
+	}
+	if !strings.Contains(data, `"author":"Alex Smith"`) {
+		t.Errorf("Expected the author in JSON events, got %s", data)
+	}
+}
+
+func TestRSSToICalSpread(t *testing.T) {
+	feed := `<?xml version="1.0"?><rss version="2.0"><channel><title>Feed</title>
+<item><title>A</title><guid>a</guid><pubDate>Mon, 01 Jan 2024 12:00:00 GMT</pubDate></item>
+<item><title>B</title><guid>b</guid><pubDate>Mon, 01 Jan 2024 12:00:00 GMT</pubDate></item>
+<item><title>C</title><guid>c</guid><pubDate>Mon, 01 Jan 2024 12:00:00 GMT</pubDate></item>
+<item><title>D</title><guid>d</guid><pubDate>Tue, 02 Jan 2024 08:00:00 GMT</pubDate></item>
+</channel></rss>`
+	rss := &RSS{}
+	if err := parseRSSFromString(feed, rss); err != nil {
+		t.Fatalf("Failed to parse RSS: %v", err)
+	}
+
+	ical, err := rssToICal(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+	if n := strings.Count(ical, "DTSTART:20240101T120000Z"); n != 3 {
+		t.Errorf("Expected the original times without spread, got %d events at 12:00", n)
+	}
+
+	opts, err := parseCalendarOptions(url.Values{"spread": {"30m"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ical, err = rssToICal(rss, opts)
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+	for uid, want := range map[string]string{
+		"a": "DTSTART:20240101T120000Z\r\nDTEND:20240101T130000Z",
+		"b": "DTSTART:20240101T123000Z\r\nDTEND:20240101T133000Z",
+		"c": "DTSTART:20240101T130000Z\r\nDTEND:20240101T140000Z",
+		"d": "DTSTART:20240102T080000Z\r\nDTEND:20240102T090000Z",
+	} {
+		event := ical[strings.Index(ical, "UID:"+uid+"\r\n"):]
+		event = event[:strings.Index(event, "END:VEVENT")]
+		if !strings.Contains(event, want) {
+			t.Errorf("Expected %s for %s, got:\n%s", want, uid, event)
+		}
+	}
+
+	for _, raw := range []string{"0", "-5m", "soon"} {
+		if _, err := parseCalendarOptions(url.Values{"spread": {raw}}); err == nil {
+			t.Errorf("Expected an error for spread %q", raw)
+		}
+	}
+}
+
+func TestCalendarHandlerEmptyFeed(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(`<?xml version="1.0"?><rss version="2.0"><channel><title>Quiet Feed</title></channel></rss>`))
+	}))
+	defer mockServer.Close()
+
+	cache = &Cache{}
+	failures = &NegativeCache{}
+
+	req := httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil)
+	w := httptest.NewRecorder()
+	calendarHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("X-Event-Count"); got != "0" {
+		t.Errorf("Expected X-Event-Count 0, got %q", got)
+	}
+
+	body := w.Body.String()
+	for _, exp := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"VERSION:2.0\r\n",
+		"PRODID:" + defaultProductID + "\r\n",
+		"NAME:Quiet Feed\r\n",
+		"REFRESH-INTERVAL;VALUE=DURATION:",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(body, exp) {
+			t.Errorf("Expected empty calendar to contain %q, got: %s", exp, body)
+		}
+	}
+	if strings.Contains(body, "BEGIN:VEVENT") {
+		t.Errorf("Expected no events, got: %s", body)
+	}
+}
+
+func TestRSSToICalUntitledEmptyFeed(t *testing.T) {
+	ical, err := rssToICal(&RSS{}, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+	if !strings.Contains(ical, "NAME:"+defaultCalendarName+"\r\n") {
+		t.Errorf("Expected the default calendar name, got: %s", ical)
+	}
+}
+
+func TestCalendarHandlerEventCount(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(mockRSSFeed))
+	}))
+	defer mockServer.Close()
+
+	cache = &Cache{}
+	failures = &NegativeCache{}
+
+	for _, format := range []string{formatICS, formatJSON} {
+		req := httptest.NewRequest("GET", "/calendar?format="+format+"&url="+url.QueryEscape(mockServer.URL), nil)
+		w := httptest.NewRecorder()
+		calendarHandler(w, req)
+
+		if got := w.Header().Get("X-Event-Count"); got != "2" {
+			t.Errorf("Expected X-Event-Count 2 for %s, got %q", format, got)
+		}
+	}
+}
+
+func TestRSSToICalFixedTime(t *testing.T) {
+	feed := `<?xml version="1.0"?><rss version="2.0"><channel><title>Digest</title>
+<item><title>Monday</title><guid>mon</guid><pubDate>Mon, 01 Jan 2024 00:00:00 GMT</pubDate></item>
+<item><title>Tuesday</title><guid>tue</guid><pubDate>Tue, 02 Jan 2024 03:00:00 GMT</pubDate></item>
+</channel></rss>`
+	rss := &RSS{}
+	if err := parseRSSFromString(feed, rss); err != nil {
+		t.Fatalf("Failed to parse RSS: %v", err)
+	}
+
+	opts, err := parseCalendarOptions(url.Values{"at": {"09:00"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ical, err := rssToICal(rss, opts)
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+	for _, exp := range []string{
+		"DTSTART:20240101T090000Z\r\nDTEND:20240101T100000Z",
+		"DTSTART:20240102T090000Z\r\nDTEND:20240102T100000Z",
+	} {
+		if !strings.Contains(ical, exp) {
+			t.Errorf("Expected iCal to contain %q, got: %s", exp, ical)
+		}
+	}
+
+	// The date is taken in tz: 03:00 UTC on the 2nd is still the 1st in New York
+	opts, err = parseCalendarOptions(url.Values{"at": {"9:30"}, "tz": {"America/New_York"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ical, err = rssToICal(rss, opts)
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+	for _, exp := range []string{
+		"DTSTART;TZID=America/New_York:20231231T093000",
+		"DTSTART;TZID=America/New_York:20240101T093000",
+	} {
+		if !strings.Contains(ical, exp) {
+			t.Errorf("Expected iCal to contain %q, got: %s", exp, ical)
+		}
+	}
+
+	for _, raw := range []string{"9am", "25:00", "09:60", "09:00:00"} {
+		if _, err := parseCalendarOptions(url.Values{"at": {raw}}); err == nil {
+			t.Errorf("Expected an error for at %q", raw)
+		}
+	}
+}
+
+func TestUnfoldWriterSplitWrites(t *testing.T) {
+	ical := "BEGIN:VEVENT\r\nDESCRIPTION:A long\r\n  line\r\nEND:VEVENT\r\n"
+	var b strings.Builder
+	unfolded := &unfoldWriter{w: &b}
+	// One byte at a time splits every line break across writes
+	for i := range ical {
+		unfolded.Write([]byte{ical[i]})
+	}
+	if err := unfolded.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got, expected := b.String(), unfoldLines(ical); got != expected || got != "BEGIN:VEVENT\nDESCRIPTION:A long line\nEND:VEVENT\n" {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+// failingWriter is a response whose body stops accepting writes, as when the
+// client goes away mid-response.
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("connection reset by peer")
+}
+
+func (w failingWriter) WriteString(string) (int, error) {
+	return w.Write(nil)
+}
+
+func TestStreamCalendarAbortsOnWriteError(t *testing.T) {
+	for _, encoding := range []string{"", "gzip"} {
+		req := httptest.NewRequest("GET", "/calendar", nil)
+		req.Header.Set("Accept-Encoding", encoding)
+		w := failingWriter{httptest.NewRecorder()}
+
+		func() {
+			defer func() {
+				if r := recover(); r != http.ErrAbortHandler {
+					t.Errorf("Expected a failed %q write to abort the handler, got %v", encoding, r)
+				}
+			}()
+			streamCalendar(w, req, "text/calendar", func(out io.Writer) error {
+				_, err := io.WriteString(out, strings.Repeat("X", 64*1024))
+				return err
+			})
+		}()
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// MediaThumbnail is a Media RSS <media:thumbnail>.
+type MediaThumbnail struct {
+	URL    string `xml:"url,attr"`
+	Width  string `xml:"width,attr"`
+	Height string `xml:"height,attr"`
+}
+
+// MediaContent is a Media RSS <media:content>, which may carry its own
+// thumbnails.
+type MediaContent struct {
+	URL        string           `xml:"url,attr"`
+	Type       string           `xml:"type,attr"`
+	Medium     string           `xml:"medium,attr"`
+	Width      string           `xml:"width,attr"`
+	Height     string           `xml:"height,attr"`
+	Thumbnails []MediaThumbnail `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+}
+
+// MediaGroup is a Media RSS <media:group>, used by YouTube to wrap an
+// entry's media elements.
+type MediaGroup struct {
+	Title       string           `xml:"http://search.yahoo.com/mrss/ title"`
+	Description string           `xml:"http://search.yahoo.com/mrss/ description"`
+	Thumbnails  []MediaThumbnail `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+	Contents    []MediaContent   `xml:"http://search.yahoo.com/mrss/ content"`
+}
+
+// Media holds an item's Media RSS elements, at the top level or grouped.
+type Media struct {
+	Thumbnails []MediaThumbnail `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+	Contents   []MediaContent   `xml:"http://search.yahoo.com/mrss/ content"`
+	Groups     []MediaGroup     `xml:"http://search.yahoo.com/mrss/ group"`
+}
+
+// image returns the URL of the largest thumbnail, or of an image
+// <media:content> when there are no thumbnails. Dimensions that are
+// missing count as zero, so the first candidate wins a tie.
+func (m Media) image() string {
+	thumbnails := append([]MediaThumbnail(nil), m.Thumbnails...)
+	contents := append([]MediaContent(nil), m.Contents...)
+	for _, group := range m.Groups {
+		thumbnails = append(thumbnails, group.Thumbnails...)
+		contents = append(contents, group.Contents...)
+	}
+	for _, content := range contents {
+		thumbnails = append(thumbnails, content.Thumbnails...)
+	}
+	if len(thumbnails) == 0 {
+		for _, content := range contents {
+			if content.Medium == "image" || strings.HasPrefix(content.Type, "image/") {
+				thumbnails = append(thumbnails, MediaThumbnail{URL: content.URL, Width: content.Width, Height: content.Height})
+			}
+		}
+	}
+
+	var best string
+	bestArea := -1
+	for _, thumbnail := range thumbnails {
+		url := strings.TrimSpace(thumbnail.URL)
+		if url == "" {
+			continue
+		}
+		if area := mediaDimension(thumbnail.Width) * mediaDimension(thumbnail.Height); area > bestArea {
+			best, bestArea = url, area
+		}
+	}
+	return best
+}
+
+// mediaDimension parses a width or height attribute, treating anything
+// unusable as zero.
+func mediaDimension(raw string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
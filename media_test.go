@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRSSToICalMediaThumbnail(t *testing.T) {
+	feed := `<?xml version="1.0"?>
+<rss version="2.0" xmlns:media="http://search.yahoo.com/mrss/">
+<channel><title>Videos</title>
+<item>
+  <title>Launch Stream</title>
+  <guid>video-1</guid>
+  <pubDate>Mon, 01 Jan 2024 12:00:00 GMT</pubDate>
+  <media:thumbnail url="https://img.example.com/small.jpg" width="120" height="90"/>
+  <media:thumbnail url="https://img.example.com/large.jpg?size=hq" width="480" height="360"/>
+  <media:thumbnail url="https://img.example.com/medium.jpg" width="320" height="180"/>
+</item>
+<item>
+  <title>Photo</title>
+  <guid>photo-1</guid>
+  <pubDate>Tue, 02 Jan 2024 12:00:00 GMT</pubDate>
+  <media:content url="https://img.example.com/photo.png" medium="image"/>
+</item>
+</channel></rss>`
+
+	rss := &RSS{}
+	if err := parseRSSFromString(feed, rss); err != nil {
+		t.Fatalf("Failed to parse RSS: %v", err)
+	}
+	ical, err := rssToICal(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+	ical = unfoldICal(ical)
+
+	for _, want := range []string{
+		"IMAGE;VALUE=URI:https://img.example.com/large.jpg?size=hq\r\n",
+		"IMAGE;VALUE=URI:https://img.example.com/photo.png\r\n",
+	} {
+		if !strings.Contains(ical, want) {
+			t.Errorf("Expected %q in output, got: %s", want, ical)
+		}
+	}
+	if strings.Contains(ical, "small.jpg") || strings.Contains(ical, "medium.jpg") {
+		t.Errorf("Expected only the largest thumbnail, got: %s", ical)
+	}
+}
+
+func TestAtomMediaGroupThumbnail(t *testing.T) {
+	feed := `<?xml version="1.0"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:media="http://search.yahoo.com/mrss/">
+<title>Channel</title>
+<entry>
+  <id>yt:video:abc123</id>
+  <title>New Video</title>
+  <published>2024-01-01T12:00:00+00:00</published>
+  <media:group>
+    <media:title>New Video</media:title>
+    <media:content url="https://www.youtube.com/v/abc123" type="application/x-shockwave-flash" width="640" height="390"/>
+    <media:thumbnail url="https://i.ytimg.com/vi/abc123/hqdefault.jpg" width="480" height="360"/>
+  </media:group>
+</entry>
+</feed>`
+
+	rss, err := parseRSS([]byte(feed))
+	if err != nil {
+		t.Fatalf("Failed to parse Atom: %v", err)
+	}
+	if got := rss.Channel.Items[0].image(); got != "https://i.ytimg.com/vi/abc123/hqdefault.jpg" {
+		t.Errorf("Expected the group thumbnail, got %q", got)
+	}
+}
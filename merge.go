@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// feedURLs returns the feed URLs requested through repeated url= parameters
+// or a comma-separated list. A comma only separates feeds when it is followed
+// by another http(s) URL, so commas inside a feed's own query string survive.
+func feedURLs(values []string) []string {
+	var urls []string
+	for _, value := range values {
+		first := len(urls)
+		for _, part := range strings.Split(value, ",") {
+			trimmed := strings.TrimSpace(part)
+			startsFeed := strings.HasPrefix(trimmed, "http://") || strings.HasPrefix(trimmed, "https://")
+			if len(urls) > first && !startsFeed {
+				urls[len(urls)-1] += "," + part
+				continue
+			}
+			if trimmed != "" {
+				urls = append(urls, trimmed)
+			}
+		}
+	}
+	return urls
+}
+
+// fetchMerged fetches several feeds concurrently and merges their items into
+// one document. URLs that could not be fetched are returned in failed; an
+// error is returned only when every feed fails. Feeds are read up to
+// maxPages pages deep when maxPages is more than one.
+func fetchMerged(ctx context.Context, urls []string, maxPages int) (*RSS, []string, error) {
+	results := make([]*RSS, len(urls))
+	errs := make([]error, len(urls))
+
+	var wg sync.WaitGroup
+	for i, feedURL := range urls {
+		wg.Add(1)
+		go func(i int, feedURL string) {
+			defer wg.Done()
+			if failures.Failed(feedURL) {
+				errs[i] = errRecentFailure
+				return
+			}
+			results[i], errs[i] = fetchRSS(ctx, feedURL)
+			if errors.Is(errs[i], errFetchBusy) || (errs[i] != nil && fetchAbandoned(ctx, errs[i])) {
+				// The feed itself didn't fail, so it isn't remembered
+				return
+			}
+			if errs[i] != nil {
+				failures.Remember(feedURL)
+				return
+			}
+			failures.Forget(feedURL)
+			if maxPages > 1 {
+				results[i] = withNextPages(ctx, results[i], feedURL, maxPages)
+			}
+		}(i, feedURL)
+	}
+	wg.Wait()
+
+	merged := &RSS{}
+	var titles, failed []string
+	for i, rss := range results {
+		if errs[i] != nil {
+			if !fetchAbandoned(ctx, errs[i]) {
+				loggerFrom(ctx).Error("feed fetch failed", "url", redactURL(urls[i]), "error", errs[i])
+			}
+			failed = append(failed, redactURL(urls[i]))
+			continue
+		}
+
+		titles = append(titles, rss.Channel.Title)
+		if ttl := rss.Channel.TTL; ttl > 0 && (merged.Channel.TTL == 0 || ttl < merged.Channel.TTL) {
+			merged.Channel.TTL = ttl
+		}
+
+		// Qualify GUIDs by feed so two feeds reusing a GUID stay distinct
+		namespace := feedNamespace(urls[i])
+		for _, item := range rss.Channel.Items {
+			item.GUID = item.uid() + "@" + namespace
+			merged.Channel.Items = append(merged.Channel.Items, item)
+		}
+	}
+
+	if len(failed) == len(urls) {
+		return nil, failed, fmt.Errorf("all %d feeds failed to fetch", len(urls))
+	}
+
+	if dropped := merged.Channel.truncateItems(maxItems); dropped > 0 {
+		loggerFrom(ctx).Warn("merged feeds have too many items, truncating",
+			"items", maxItems+dropped, "max_items", maxItems)
+	}
+	merged.Channel.Title = strings.Join(titles, ", ")
+	return merged, failed, nil
+}
+
+// feedNamespace is a short, stable identifier for a feed URL.
+func feedNamespace(feedURL string) string {
+	sum := sha1.Sum([]byte(feedURL))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// serveMergedCalendar handles a request for several feeds combined into one
+// calendar. Feeds that fail are listed in the X-Failed-Feeds header.
+func serveMergedCalendar(w http.ResponseWriter, r *http.Request, key string, urls []string, opts CalendarOptions) {
+	rss, failed, err := fetchMerged(r.Context(), urls, opts.MaxPages)
+	if r.Context().Err() != nil {
+		// The client is gone, so there's no one to answer
+		return
+	}
+	if err != nil && opts.ErrorsInline {
+		writeFeedError(w, r, opts, err)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch RSS feed", http.StatusInternalServerError)
+		return
+	}
+
+	stale, _ := cache.Lookup(key)
+	conv, err := prepareCalendar(r.Context(), rss, opts, stale.versions)
+	if err != nil {
+		loggerFrom(r.Context()).Error("merged calendar conversion failed", "error", err)
+		http.Error(w, "Failed to convert to iCalendar", http.StatusInternalServerError)
+		return
+	}
+
+	// Only complete calendars are cached so a transient failure isn't pinned
+	// for the whole TTL
+	entry := conv.entry
+	entry.ttl = rss.Channel.declaredTTL()
+	if len(failed) > 0 {
+		w.Header().Set("X-Failed-Feeds", strings.Join(failed, ", "))
+	}
+	entry, err = writeConversion(w, r, opts.contentType(), conv, entry)
+	if err != nil {
+		loggerFrom(r.Context()).Error("merged calendar write failed", "error", err)
+		return
+	}
+	if len(failed) == 0 {
+		cache.Store(key, entry)
+	}
+}
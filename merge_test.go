@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFeedURLs(t *testing.T) {
+	tests := []struct {
+		values   []string
+		expected []string
+	}{
+		{nil, nil},
+		{[]string{"https://a.com/rss"}, []string{"https://a.com/rss"}},
+		{[]string{"https://a.com/rss", "https://b.com/rss"}, []string{"https://a.com/rss", "https://b.com/rss"}},
+		{[]string{"https://a.com/rss, https://b.com/rss"}, []string{"https://a.com/rss", "https://b.com/rss"}},
+		{[]string{"https://a.com/rss?tags=x,y"}, []string{"https://a.com/rss?tags=x,y"}},
+		{[]string{"https://a.com/rss?tags=x,y,https://b.com/rss"}, []string{"https://a.com/rss?tags=x,y", "https://b.com/rss"}},
+	}
+
+	for _, test := range tests {
+		if result := feedURLs(test.values); !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("feedURLs(%q) = %q, expected %q", test.values, result, test.expected)
+		}
+	}
+}
+
+func TestCalendarHandlerMergesFeeds(t *testing.T) {
+	// Both feeds share GUIDs, which must not collide once merged
+	feedA := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(mockRSSFeed))
+	}))
+	defer feedA.Close()
+	feedB := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Replace(mockRSSFeed, "Test RSS Feed", "Second Feed", 1)))
+	}))
+	defer feedB.Close()
+
+	// Clear cache for clean test
+	cache = &Cache{}
+
+	query := url.Values{"url": {feedA.URL, feedB.URL}}
+	req := httptest.NewRequest("GET", "/calendar?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+
+	calendarHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", w.Code)
+	}
+
+	body := w.Body.String()
+	if count := strings.Count(body, "BEGIN:VEVENT"); count != 4 {
+		t.Errorf("Expected 4 VEVENTs from two feeds, got %d", count)
+	}
+
+	uids := map[string]bool{}
+	for _, line := range strings.Split(body, "\r\n") {
+		if strings.HasPrefix(line, "UID:") {
+			if uids[line] {
+				t.Errorf("Duplicate %s in merged calendar", line)
+			}
+			uids[line] = true
+		}
+	}
+
+	if header := w.Header().Get("X-Failed-Feeds"); header != "" {
+		t.Errorf("Expected no failed feeds, got '%s'", header)
+	}
+}
+
+func TestCalendarHandlerMergePartialFailure(t *testing.T) {
+	goodFeed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(mockRSSFeed))
+	}))
+	defer goodFeed.Close()
+	badFeed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer badFeed.Close()
+
+	// Clear cache for clean test
+	cache = &Cache{}
+
+	query := url.Values{"url": {goodFeed.URL + "," + badFeed.URL}}
+	req := httptest.NewRequest("GET", "/calendar?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+
+	calendarHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", w.Code)
+	}
+	if count := strings.Count(w.Body.String(), "BEGIN:VEVENT"); count != 2 {
+		t.Errorf("Expected 2 VEVENTs from the working feed, got %d", count)
+	}
+	if header := w.Header().Get("X-Failed-Feeds"); header != badFeed.URL {
+		t.Errorf("Expected X-Failed-Feeds '%s', got '%s'", badFeed.URL, header)
+	}
+}
+
+func TestCalendarHandlerMergeAllFail(t *testing.T) {
+	badFeed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer badFeed.Close()
+
+	// Clear cache for clean test
+	cache = &Cache{}
+
+	query := url.Values{"url": {badFeed.URL + "/a", badFeed.URL + "/b"}}
+	req := httptest.NewRequest("GET", "/calendar?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+
+	calendarHandler(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status code 500, got %d", w.Code)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rss2ical_requests_total",
+		Help: "Calendar requests served, by HTTP status code.",
+	}, []string{"status"})
+
+	cacheHits = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "rss2ical_cache_hits_total",
+		Help: "Calendar requests served from the cache.",
+	})
+
+	cacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "rss2ical_cache_misses_total",
+		Help: "Calendar requests that required an upstream fetch.",
+	})
+
+	fetchDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "rss2ical_fetch_duration_seconds",
+		Help:    "Time spent fetching and parsing upstream feeds.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	fetchErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "rss2ical_fetch_errors_total",
+		Help: "Upstream feed fetches that failed.",
+	})
+)
+
+// statusRecorder captures the status code written through it so it can be
+// reported once the handler returns.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// histogramCount returns the number of observations recorded by h.
+func histogramCount(t *testing.T, h prometheus.Histogram) uint64 {
+	t.Helper()
+	var m dto.Metric
+	if err := h.Write(&m); err != nil {
+		t.Fatalf("Failed to read histogram: %v", err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestMetrics(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(mockRSSFeed))
+	}))
+	defer mockServer.Close()
+
+	// Clear cache for clean test
+	cache = &Cache{}
+
+	hits := testutil.ToFloat64(cacheHits)
+	misses := testutil.ToFloat64(cacheMisses)
+	ok := testutil.ToFloat64(requestsTotal.WithLabelValues("200"))
+	fetches := histogramCount(t, fetchDuration)
+
+	// The first request misses the cache and the second is served from it
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil)
+		calendarHandler(httptest.NewRecorder(), req)
+	}
+
+	if got := testutil.ToFloat64(cacheMisses) - misses; got != 1 {
+		t.Errorf("Expected 1 cache miss, got %v", got)
+	}
+	if got := testutil.ToFloat64(cacheHits) - hits; got != 1 {
+		t.Errorf("Expected 1 cache hit, got %v", got)
+	}
+	if got := testutil.ToFloat64(requestsTotal.WithLabelValues("200")) - ok; got != 2 {
+		t.Errorf("Expected 2 successful requests, got %v", got)
+	}
+	if got := histogramCount(t, fetchDuration) - fetches; got != 1 {
+		t.Errorf("Expected 1 observed fetch, got %d", got)
+	}
+
+	w := httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	for _, name := range []string{
+		"rss2ical_requests_total",
+		"rss2ical_cache_hits_total",
+		"rss2ical_cache_misses_total",
+		"rss2ical_fetch_duration_seconds",
+		"rss2ical_fetch_errors_total",
+	} {
+		if !strings.Contains(body, name) {
+			t.Errorf("Expected /metrics to expose %s", name)
+		}
+	}
+}
@@ -0,0 +1,468 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+const defaultEventDuration = time.Hour
+
+// eventDuration is the event length used when a request doesn't specify one.
+var eventDuration = defaultEventDuration
+
+// upcomingGrace is how long after starting an event still counts as
+// upcoming, so one in progress doesn't vanish the moment it begins.
+const upcomingGrace = time.Hour
+
+// uidPrefixPattern matches the characters allowed in a uid_prefix.
+var uidPrefixPattern = regexp.MustCompile(`^[A-Za-z0-9._@:-]{1,64}$`)
+
+// maxProductIDLength and maxCalendarNameLength bound the prodid and name
+// overrides, in characters.
+const (
+	maxProductIDLength    = 128
+	maxCalendarNameLength = 256
+)
+
+// elementNamePattern matches an XML element name with an optional prefix.
+var elementNamePattern = regexp.MustCompile(`^([A-Za-z_][\w.-]*:)?[A-Za-z_][\w.-]*$`)
+
+// CalendarOptions controls how feed items are converted into events.
+type CalendarOptions struct {
+	Duration time.Duration
+	AllDay   bool
+	// Location, when set, is the timezone events are expressed in and the
+	// zone assumed for feed dates that carry no offset.
+	Location *time.Location
+	// FullDescription uses an item's <content:encoded> body, when it has
+	// one, instead of its <description> summary.
+	FullDescription bool
+	// RawHTML keeps item descriptions as-is instead of converting them to
+	// plain text.
+	RawHTML bool
+	// DateFromContent takes the event start from a date mentioned in the
+	// item description, falling back to the publish date.
+	DateFromContent bool
+	// Limit caps the number of events to the most recent items; zero means
+	// no limit.
+	Limit int
+	// Contains keeps only items mentioning at least one of these terms, and
+	// Excludes drops items mentioning any of them. Terms are lowercase.
+	Contains []string
+	Excludes []string
+	// Match keeps only items whose MatchField ("title", "description" or
+	// "all") matches the expression.
+	Match      *regexp.Regexp
+	MatchField string
+	// LocationField names the item element used for the event LOCATION.
+	LocationField string
+	// SortDescending emits events newest first instead of oldest first.
+	SortDescending bool
+	// Format is the response format, formatICS or formatJSON.
+	Format string
+	// Alarm, when positive, adds a display reminder this long before each
+	// event starts.
+	Alarm time.Duration
+	// After and Before, when set, keep only dated events starting at or
+	// after After and before Before.
+	After  time.Time
+	Before time.Time
+	// Method is the calendar METHOD; empty omits the property.
+	Method ics.Method
+	// UIDPrefix is prepended to every event UID.
+	UIDPrefix string
+	// ProductID and Name, when set, replace the calendar's PRODID and the
+	// feed title used as its name.
+	ProductID string
+	Name      string
+	// Busy marks events OPAQUE so they block time in free/busy lookups;
+	// otherwise they are TRANSPARENT, as suits informational items.
+	Busy bool
+	// Status is every event's STATUS; empty omits the property.
+	Status ics.ObjectStatus
+	// MaxPages, when more than one, follows rel="next" links to read up to
+	// this many pages of a paged feed.
+	MaxPages int
+	// Unfolded writes each property on one LF-terminated line, for importers
+	// that can't handle RFC 5545's CRLF endings and 75-octet folding.
+	Unfolded bool
+	// Discover follows the feed link of a URL that turns out to be a web
+	// page, e.g. a site's homepage.
+	Discover bool
+	// Spread staggers items sharing a start time by this much each; zero
+	// keeps their times.
+	Spread time.Duration
+	// FixedTime moves every dated event to At past midnight on its date in
+	// the event timezone, for digests published at midnight.
+	FixedTime bool
+	At        time.Duration
+	// ErrorsInline answers a feed that fails to fetch with a calendar
+	// holding one event describing the error, instead of a 5xx.
+	ErrorsInline bool
+}
+
+func defaultCalendarOptions() CalendarOptions {
+	return CalendarOptions{
+		Duration:        eventDuration,
+		FullDescription: true,
+		MatchField:      "title",
+		Format:          formatICS,
+		Method:          ics.MethodPublish,
+		Status:          ics.ObjectStatusConfirmed,
+	}
+}
+
+// parseCalendarOptions reads conversion options from the request query,
+// starting from the defaults for anything that isn't specified.
+func parseCalendarOptions(query url.Values) (CalendarOptions, error) {
+	opts := defaultCalendarOptions()
+
+	if raw := query.Get("duration"); raw != "" {
+		duration, err := time.ParseDuration(raw)
+		if err != nil || duration < 0 {
+			return opts, fmt.Errorf("invalid duration %q: use a value like 30m, 2h or 0", raw)
+		}
+		opts.Duration = duration
+	}
+
+	if raw := query.Get("spread"); raw != "" {
+		spread, err := time.ParseDuration(raw)
+		if err != nil || spread <= 0 {
+			return opts, fmt.Errorf("invalid spread %q: use a positive value like 15m or 1h", raw)
+		}
+		opts.Spread = spread
+	}
+
+	if raw := query.Get("at"); raw != "" {
+		at, err := time.Parse("15:04", raw)
+		if err != nil {
+			return opts, fmt.Errorf("invalid at %q: use a time of day like 09:00", raw)
+		}
+		opts.FixedTime = true
+		opts.At = time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute
+	}
+
+	if raw := query.Get("errorsInline"); raw != "" {
+		errorsInline, err := strconv.ParseBool(raw)
+		if err != nil {
+			return opts, fmt.Errorf("invalid errorsInline %q: use true or false", raw)
+		}
+		opts.ErrorsInline = errorsInline
+	}
+
+	if raw := query.Get("allday"); raw != "" {
+		allDay, err := strconv.ParseBool(raw)
+		if err != nil {
+			return opts, fmt.Errorf("invalid allday %q: use true or false", raw)
+		}
+		opts.AllDay = allDay
+	}
+
+	if raw := query.Get("tz"); raw != "" {
+		loc, err := time.LoadLocation(raw)
+		if err != nil {
+			return opts, fmt.Errorf("invalid tz %q: use an IANA name like America/New_York", raw)
+		}
+		opts.Location = loc
+	}
+
+	switch raw := query.Get("html"); raw {
+	case "", "text":
+	case "raw":
+		opts.RawHTML = true
+	default:
+		return opts, fmt.Errorf("invalid html %q: use raw or text", raw)
+	}
+
+	switch raw := query.Get("desc"); raw {
+	case "", "full":
+	case "summary":
+		opts.FullDescription = false
+	default:
+		return opts, fmt.Errorf("invalid desc %q: use full or summary", raw)
+	}
+
+	switch raw := query.Get("datefrom"); raw {
+	case "", "pubdate":
+	case "content":
+		opts.DateFromContent = true
+	default:
+		return opts, fmt.Errorf("invalid datefrom %q: use pubdate or content", raw)
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 0 {
+			return opts, fmt.Errorf("invalid limit %q: use a non-negative integer", raw)
+		}
+		opts.Limit = limit
+	}
+
+	opts.Contains = splitTerms(query.Get("contains"))
+	opts.Excludes = splitTerms(query.Get("excludes"))
+
+	if raw := query.Get("match"); raw != "" {
+		match, err := regexp.Compile(raw)
+		if err != nil {
+			return opts, fmt.Errorf("invalid match pattern: %v", err)
+		}
+		opts.Match = match
+	}
+
+	switch raw := query.Get("matchfield"); raw {
+	case "":
+	case "title", "description", "all":
+		opts.MatchField = raw
+	default:
+		return opts, fmt.Errorf("invalid matchfield %q: use title, description or all", raw)
+	}
+
+	if raw := query.Get("locationfield"); raw != "" {
+		if !elementNamePattern.MatchString(raw) {
+			return opts, fmt.Errorf("invalid locationfield %q: use an element name like location or georss:point", raw)
+		}
+		opts.LocationField = raw
+	}
+
+	switch raw := query.Get("sort"); raw {
+	case "", "asc":
+	case "desc":
+		opts.SortDescending = true
+	default:
+		return opts, fmt.Errorf("invalid sort %q: use asc or desc", raw)
+	}
+
+	switch raw := query.Get("format"); raw {
+	case "", formatICS:
+	case formatJSON:
+		opts.Format = formatJSON
+	default:
+		return opts, fmt.Errorf("invalid format %q: use ics or json", raw)
+	}
+
+	switch raw := query.Get("method"); raw {
+	case "", "publish":
+	case "request":
+		opts.Method = ics.MethodRequest
+	case "none":
+		opts.Method = ""
+	default:
+		return opts, fmt.Errorf("invalid method %q: use publish, request or none", raw)
+	}
+
+	if raw := query.Get("uid_prefix"); raw != "" {
+		if !uidPrefixPattern.MatchString(raw) {
+			return opts, fmt.Errorf("invalid uid_prefix %q: use up to 64 letters, digits or . _ @ : -", raw)
+		}
+		opts.UIDPrefix = raw
+	}
+
+	if raw := query.Get("busy"); raw != "" {
+		busy, err := strconv.ParseBool(raw)
+		if err != nil {
+			return opts, fmt.Errorf("invalid busy %q: use true or false", raw)
+		}
+		opts.Busy = busy
+	}
+
+	if raw := query.Get("discover"); raw != "" {
+		discover, err := strconv.ParseBool(raw)
+		if err != nil {
+			return opts, fmt.Errorf("invalid discover %q: use true or false", raw)
+		}
+		opts.Discover = discover
+	}
+
+	if raw := query.Get("strict"); raw != "" {
+		strict, err := strconv.ParseBool(raw)
+		if err != nil {
+			return opts, fmt.Errorf("invalid strict %q: use true or false", raw)
+		}
+		opts.Unfolded = !strict
+	}
+
+	switch raw := query.Get("status"); raw {
+	case "", "confirmed":
+	case "tentative":
+		opts.Status = ics.ObjectStatusTentative
+	case "cancelled":
+		opts.Status = ics.ObjectStatusCancelled
+	default:
+		return opts, fmt.Errorf("invalid status %q: use confirmed, tentative or cancelled", raw)
+	}
+
+	if paginate := query.Get("paginate"); paginate != "" || query.Get("maxpages") != "" {
+		if paginate != "true" {
+			return opts, fmt.Errorf("invalid paginate %q: use paginate=true, optionally with maxpages", paginate)
+		}
+		opts.MaxPages = defaultMaxPages
+		if raw := query.Get("maxpages"); raw != "" {
+			pages, err := strconv.Atoi(raw)
+			if err != nil || pages < 1 || pages > maxPagesLimit {
+				return opts, fmt.Errorf("invalid maxpages %q: use a number from 1 to %d", raw, maxPagesLimit)
+			}
+			opts.MaxPages = pages
+		}
+	}
+
+	if raw := query.Get("prodid"); raw != "" {
+		if err := checkCalendarText(raw, maxProductIDLength); err != nil {
+			return opts, fmt.Errorf("invalid prodid: %w", err)
+		}
+		opts.ProductID = raw
+	}
+
+	if raw := query.Get("name"); raw != "" {
+		if err := checkCalendarText(raw, maxCalendarNameLength); err != nil {
+			return opts, fmt.Errorf("invalid name: %w", err)
+		}
+		opts.Name = raw
+	}
+
+	if raw := query.Get("alarm"); raw != "" {
+		alarm, err := time.ParseDuration(raw)
+		if err != nil || alarm <= 0 {
+			return opts, fmt.Errorf("invalid alarm %q: use a positive value like 15m or 1h", raw)
+		}
+		opts.Alarm = alarm
+	}
+
+	var upcoming bool
+	if raw := query.Get("upcoming"); raw != "" {
+		var err error
+		if upcoming, err = strconv.ParseBool(raw); err != nil {
+			return opts, fmt.Errorf("invalid upcoming %q: use true or false", raw)
+		}
+	}
+
+	now := time.Now()
+	if raw := query.Get("window"); raw != "" {
+		if query.Get("after") != "" {
+			return opts, fmt.Errorf("use window or after, not both")
+		}
+		window, err := parseWindow(raw)
+		if err != nil {
+			return opts, fmt.Errorf("invalid window %q: use a value like 90d, 2w or 36h", raw)
+		}
+		// With upcoming the window looks ahead instead of back
+		if upcoming {
+			opts.Before = now.Add(window)
+		} else {
+			opts.After = now.Add(-window)
+		}
+	}
+
+	if raw := query.Get("after"); raw != "" {
+		after, err := parseBound(raw, opts.location())
+		if err != nil {
+			return opts, fmt.Errorf("invalid after %q: use a date like 2024-01-31 or 2024-01-31T09:00:00Z", raw)
+		}
+		opts.After = after
+	}
+
+	if raw := query.Get("before"); raw != "" {
+		before, err := parseBound(raw, opts.location())
+		if err != nil {
+			return opts, fmt.Errorf("invalid before %q: use a date like 2024-01-31 or 2024-01-31T09:00:00Z", raw)
+		}
+		opts.Before = before
+	}
+
+	if floor := now.Add(-upcomingGrace); upcoming && opts.After.Before(floor) {
+		opts.After = floor
+	}
+
+	return opts, nil
+}
+
+// parseWindow parses a positive duration, also accepting whole days ("90d")
+// and weeks ("2w").
+func parseWindow(raw string) (time.Duration, error) {
+	var window time.Duration
+	var err error
+	if days, ok := strings.CutSuffix(raw, "d"); ok {
+		window, err = wholeUnits(days, 24*time.Hour)
+	} else if weeks, ok := strings.CutSuffix(raw, "w"); ok {
+		window, err = wholeUnits(weeks, 7*24*time.Hour)
+	} else {
+		window, err = time.ParseDuration(raw)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if window <= 0 {
+		return 0, fmt.Errorf("window must be positive")
+	}
+	return window, nil
+}
+
+func wholeUnits(raw string, unit time.Duration) (time.Duration, error) {
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// parseBound parses an RFC 3339 timestamp or a plain date, which is taken
+// as midnight in loc.
+func parseBound(raw string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", raw, loc)
+}
+
+// contentType is the media type of responses in the requested format.
+func (o CalendarOptions) contentType() string {
+	if o.Format == formatJSON {
+		return "application/json; charset=utf-8"
+	}
+	return "text/calendar; charset=utf-8"
+}
+
+// location returns the zone feed dates without an offset are read in.
+func (o CalendarOptions) location() *time.Location {
+	if o.Location != nil {
+		return o.Location
+	}
+	return time.UTC
+}
+
+// cacheKey identifies a converted calendar by its feed URL plus any options
+// that shaped it, so differently configured requests don't share an entry.
+func cacheKey(query url.Values) string {
+	options := url.Values{}
+	for name, values := range query {
+		if name != "url" {
+			options[name] = values
+		}
+	}
+
+	key := strings.Join(feedURLs(query["url"]), ",")
+	if len(options) > 0 {
+		key += "#" + options.Encode()
+	}
+	return key
+}
+
+// checkCalendarText rejects override values too long or carrying control
+// characters, which would break the property onto a new line.
+func checkCalendarText(value string, limit int) error {
+	if utf8.RuneCountInString(value) > limit {
+		return fmt.Errorf("use at most %d characters", limit)
+	}
+	if strings.IndexFunc(value, unicode.IsControl) >= 0 {
+		return errors.New("control characters are not allowed")
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseCalendarOptionsDefaults(t *testing.T) {
+	opts, err := parseCalendarOptions(url.Values{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if opts.Duration != time.Hour {
+		t.Errorf("Expected default duration of 1h, got %v", opts.Duration)
+	}
+}
+
+func TestParseCalendarOptionsDuration(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Duration
+		valid    bool
+	}{
+		{"30m", 30 * time.Minute, true},
+		{"2h", 2 * time.Hour, true},
+		{"0", 0, true},
+		{"soon", 0, false},
+		{"-1h", 0, false},
+	}
+
+	for _, test := range tests {
+		opts, err := parseCalendarOptions(url.Values{"duration": {test.input}})
+		if test.valid && err != nil {
+			t.Errorf("Expected duration %s to be valid, got error: %v", test.input, err)
+			continue
+		}
+		if !test.valid {
+			if err == nil {
+				t.Errorf("Expected error for duration %s", test.input)
+			}
+			continue
+		}
+		if opts.Duration != test.expected {
+			t.Errorf("Expected duration %v for %s, got %v", test.expected, test.input, opts.Duration)
+		}
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	plain := cacheKey(url.Values{"url": {"https://test.com/rss.xml"}})
+	if plain != "https://test.com/rss.xml" {
+		t.Errorf("Expected bare feed URL as key, got '%s'", plain)
+	}
+
+	withOptions := cacheKey(url.Values{"url": {"https://test.com/rss.xml"}, "duration": {"30m"}})
+	if withOptions == plain {
+		t.Error("Expected options to produce a distinct cache key")
+	}
+}
+
+func TestParseCalendarOptionsAllDay(t *testing.T) {
+	opts, err := parseCalendarOptions(url.Values{"allday": {"true"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !opts.AllDay {
+		t.Error("Expected allday=true to enable all-day events")
+	}
+
+	if _, err := parseCalendarOptions(url.Values{"allday": {"sometimes"}}); err == nil {
+		t.Error("Expected error for invalid allday value")
+	}
+}
+
+func TestParseCalendarOptionsTimezone(t *testing.T) {
+	opts, err := parseCalendarOptions(url.Values{"tz": {"America/New_York"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.Location == nil || opts.Location.String() != "America/New_York" {
+		t.Errorf("Expected America/New_York location, got %v", opts.Location)
+	}
+
+	if _, err := parseCalendarOptions(url.Values{"tz": {"Mars/Olympus_Mons"}}); err == nil {
+		t.Error("Expected error for invalid tz")
+	}
+}
+
+func TestParseCalendarOptionsHTML(t *testing.T) {
+	opts, err := parseCalendarOptions(url.Values{"html": {"raw"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !opts.RawHTML {
+		t.Error("Expected html=raw to preserve HTML")
+	}
+
+	if _, err := parseCalendarOptions(url.Values{"html": {"markdown"}}); err == nil {
+		t.Error("Expected error for invalid html value")
+	}
+}
+
+func TestParseCalendarOptionsDateFrom(t *testing.T) {
+	opts, err := parseCalendarOptions(url.Values{"datefrom": {"content"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !opts.DateFromContent {
+		t.Error("Expected datefrom=content to enable content dates")
+	}
+
+	if _, err := parseCalendarOptions(url.Values{"datefrom": {"title"}}); err == nil {
+		t.Error("Expected error for invalid datefrom value")
+	}
+}
+
+func TestParseCalendarOptionsLimit(t *testing.T) {
+	opts, err := parseCalendarOptions(url.Values{"limit": {"2"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.Limit != 2 {
+		t.Errorf("Expected limit 2, got %d", opts.Limit)
+	}
+
+	for _, raw := range []string{"-1", "two", "1.5"} {
+		if _, err := parseCalendarOptions(url.Values{"limit": {raw}}); err == nil {
+			t.Errorf("Expected error for limit %q", raw)
+		}
+	}
+}
+
+func TestParseCalendarOptionsLocationField(t *testing.T) {
+	opts, err := parseCalendarOptions(url.Values{"locationfield": {"georss:point"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.LocationField != "georss:point" {
+		t.Errorf("Expected georss:point, got '%s'", opts.LocationField)
+	}
+
+	if _, err := parseCalendarOptions(url.Values{"locationfield": {"<script>"}}); err == nil {
+		t.Error("Expected error for invalid element name")
+	}
+}
+
+func TestParseCalendarOptionsAlarm(t *testing.T) {
+	opts, err := parseCalendarOptions(url.Values{"alarm": {"15m"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.Alarm != 15*time.Minute {
+		t.Errorf("Expected alarm 15m, got %v", opts.Alarm)
+	}
+
+	for _, raw := range []string{"0", "-5m", "soon"} {
+		if _, err := parseCalendarOptions(url.Values{"alarm": {raw}}); err == nil {
+			t.Errorf("Expected error for alarm %q", raw)
+		}
+	}
+}
+
+func TestParseCalendarOptionsWindow(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Duration
+		valid    bool
+	}{
+		{"90d", 90 * 24 * time.Hour, true},
+		{"2w", 14 * 24 * time.Hour, true},
+		{"36h", 36 * time.Hour, true},
+		{"0d", 0, false},
+		{"-1h", 0, false},
+		{"1.5d", 0, false},
+		{"soon", 0, false},
+	}
+
+	for _, test := range tests {
+		before := time.Now()
+		opts, err := parseCalendarOptions(url.Values{"window": {test.input}})
+		if !test.valid {
+			if err == nil {
+				t.Errorf("Expected error for window %s", test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Expected window %s to be valid, got error: %v", test.input, err)
+			continue
+		}
+		if window := before.Sub(opts.After); window < test.expected-time.Second || window > test.expected+time.Second {
+			t.Errorf("Expected window %v for %s, got %v", test.expected, test.input, window)
+		}
+	}
+
+	if _, err := parseCalendarOptions(url.Values{"window": {"90d"}, "after": {"2024-01-01"}}); err == nil {
+		t.Error("Expected error for window combined with after")
+	}
+	for _, name := range []string{"after", "before"} {
+		if _, err := parseCalendarOptions(url.Values{name: {"last tuesday"}}); err == nil {
+			t.Errorf("Expected error for invalid %s", name)
+		}
+	}
+}
+
+func TestParseCalendarOptionsDesc(t *testing.T) {
+	opts, err := parseCalendarOptions(url.Values{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !opts.FullDescription {
+		t.Error("Expected full descriptions by default")
+	}
+
+	opts, err = parseCalendarOptions(url.Values{"desc": {"summary"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.FullDescription {
+		t.Error("Expected desc=summary to use the summary")
+	}
+
+	if _, err := parseCalendarOptions(url.Values{"desc": {"long"}}); err == nil {
+		t.Error("Expected error for invalid desc value")
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"net/url"
+)
+
+const (
+	// defaultMaxPages is how many pages paginate=true reads without maxpages.
+	defaultMaxPages = 10
+	// maxPagesLimit bounds maxpages, since each page is another fetch.
+	maxPagesLimit = 50
+)
+
+// nextPage returns the absolute URL of the channel's rel="next" link, as
+// RFC 5005 paged feeds give, resolved against pageURL. It is empty when
+// there is no usable next link.
+func (c Channel) nextPage(pageURL string) string {
+	for _, link := range c.Links {
+		if link.Rel != "next" || link.Href == "" {
+			continue
+		}
+		base, err := url.Parse(pageURL)
+		if err != nil {
+			return ""
+		}
+		ref, err := url.Parse(link.Href)
+		if err != nil {
+			return ""
+		}
+		next, err := normalizeFeedURL(base.ResolveReference(ref).String())
+		if err != nil {
+			return ""
+		}
+		return next
+	}
+	return ""
+}
+
+// withNextPages returns rss, the first page of the feed at feedURL, with
+// the items of up to maxPages-1 further pages reached through rel="next"
+// links appended. Pages already seen end the walk, so a feed linking back
+// to itself can't loop. A page that can't be fetched also ends it, keeping
+// the items gathered so far, as does reaching maxItems. rss itself is left
+// unchanged since fetch results are shared between requests.
+func withNextPages(ctx context.Context, rss *RSS, feedURL string, maxPages int) *RSS {
+	combined := *rss
+	combined.Channel.Items = append([]Item(nil), rss.Channel.Items...)
+
+	logger := loggerFrom(ctx).With("url", redactURL(feedURL))
+	visited := map[string]bool{feedURL: true}
+	page, pageURL := rss, feedURL
+	for pages := 1; pages < maxPages && len(combined.Channel.Items) < maxItems; pages++ {
+		next := page.Channel.nextPage(pageURL)
+		if next == "" || visited[next] {
+			break
+		}
+		visited[next] = true
+		if !hostAllowed(next) {
+			logger.Warn("next page host not allowed", "page", redactURL(next))
+			break
+		}
+
+		var err error
+		if page, err = fetchRSS(ctx, next); err != nil {
+			logger.Warn("next page fetch failed", "page", redactURL(next), "error", err)
+			break
+		}
+		combined.Channel.Items = append(combined.Channel.Items, page.Channel.Items...)
+		pageURL = next
+	}
+	if dropped := combined.Channel.truncateItems(maxItems); dropped > 0 {
+		logger.Warn("paged feed has too many items, truncating", "max_items", maxItems)
+	}
+	return &combined
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCalendarHandlerPaginate(t *testing.T) {
+	var fetches int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.Header().Set("Content-Type", "application/atom+xml")
+		switch r.URL.Query().Get("page") {
+		case "":
+			w.Write([]byte(`<?xml version="1.0"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><title>Paged</title>
+<link rel="next" href="/feed?page=2"/>
+<entry><id>item-1</id><title>Page One Item</title><published>2024-01-02T12:00:00Z</published></entry>
+</feed>`))
+		case "2":
+			// Links back to the first page, which must not be fetched again
+			w.Write([]byte(`<?xml version="1.0"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><title>Paged</title>
+<link rel="next" href="` + "http://" + r.Host + `/feed"/>
+<entry><id>item-2</id><title>Page Two Item</title><published>2024-01-01T12:00:00Z</published></entry>
+</feed>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer mockServer.Close()
+
+	feed := url.QueryEscape(mockServer.URL + "/feed")
+	tests := []struct {
+		query   string
+		titles  []string
+		fetches int32
+	}{
+		{"url=" + feed, []string{"Page One Item"}, 1},
+		{"url=" + feed + "&paginate=true", []string{"Page One Item", "Page Two Item"}, 2},
+		{"url=" + feed + "&paginate=true&maxpages=1", []string{"Page One Item"}, 1},
+	}
+	for _, tt := range tests {
+		cache = &Cache{}
+		failures = &NegativeCache{}
+		atomic.StoreInt32(&fetches, 0)
+
+		req := httptest.NewRequest("GET", "/calendar?"+tt.query, nil)
+		w := httptest.NewRecorder()
+		calendarHandler(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status code 200, got %d", tt.query, w.Code)
+		}
+
+		body := w.Body.String()
+		if n := strings.Count(body, "BEGIN:VEVENT"); n != len(tt.titles) {
+			t.Errorf("%s: expected %d events, got %d", tt.query, len(tt.titles), n)
+		}
+		for _, title := range tt.titles {
+			if !strings.Contains(body, "SUMMARY:"+title) {
+				t.Errorf("%s: expected %q in output", tt.query, title)
+			}
+		}
+		if n := atomic.LoadInt32(&fetches); n != tt.fetches {
+			t.Errorf("%s: expected %d fetches, got %d", tt.query, tt.fetches, n)
+		}
+	}
+}
+
+func TestChannelNextPage(t *testing.T) {
+	rss, err := parseRSS([]byte(`<?xml version="1.0"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom"><channel>
+<title>Paged RSS</title>
+<link>https://example.com/</link>
+<atom:link rel="self" href="https://example.com/feed"/>
+<atom:link rel="next" href="feed?page=2"/>
+</channel></rss>`))
+	if err != nil {
+		t.Fatalf("Failed to parse RSS: %v", err)
+	}
+	if got := rss.Channel.nextPage("https://example.com/feed"); got != "https://example.com/feed?page=2" {
+		t.Errorf("Expected the resolved next page, got %q", got)
+	}
+}
+
+func TestParseCalendarOptionsPaginate(t *testing.T) {
+	for _, query := range []url.Values{
+		{"paginate": {"yes"}},
+		{"maxpages": {"3"}},
+		{"paginate": {"true"}, "maxpages": {"0"}},
+		{"paginate": {"true"}, "maxpages": {"51"}},
+	} {
+		if _, err := parseCalendarOptions(query); err == nil {
+			t.Errorf("Expected an error for %v", query)
+		}
+	}
+
+	opts, err := parseCalendarOptions(url.Values{"paginate": {"true"}})
+	if err != nil || opts.MaxPages != defaultMaxPages {
+		t.Errorf("Expected %d pages by default, got %d (%v)", defaultMaxPages, opts.MaxPages, err)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// podcastNamespaces are the URIs feeds declare the Podcasting 2.0 podcast:
+// prefix with; early adopters used the specification's GitHub page. Tags
+// other than those read below are ignored.
+var podcastNamespaces = map[string]bool{
+	"https://podcastindex.org/namespace/1.0":                                      true,
+	"https://github.com/Podcastindex-org/podcast-namespace/blob/main/docs/1.0.md": true,
+}
+
+// PodcastLocation is a <podcast:location>: the place an episode was
+// recorded or is about, with optional RFC 5870 coordinates such as
+// "geo:30.2672,-97.7431".
+type PodcastLocation struct {
+	Name string `xml:",chardata"`
+	Geo  string `xml:"geo,attr"`
+}
+
+// podcastLocation returns the place named by <podcast:location>.
+func (i Item) podcastLocation() string {
+	return strings.TrimSpace(i.PodcastLocation.Name)
+}
+
+// podcastGeo returns the coordinates of <podcast:location>'s geo URI,
+// ignoring any altitude and parameters.
+func (i Item) podcastGeo() (lat, lon float64, ok bool) {
+	coordinates, found := strings.CutPrefix(strings.ToLower(strings.TrimSpace(i.PodcastLocation.Geo)), "geo:")
+	if !found {
+		return 0, 0, false
+	}
+	coordinates, _, _ = strings.Cut(coordinates, ";")
+	parts := strings.Split(coordinates, ",")
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+	return parseCoordinates(parts[0], parts[1])
+}
+
+// podcastPersons returns the names of the people <podcast:person> credits
+// on the item, such as its hosts and guests.
+func (i Item) podcastPersons() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, extra := range i.Extra {
+		if !podcastNamespaces[extra.XMLName.Space] || extra.XMLName.Local != "person" {
+			continue
+		}
+		name := strings.TrimSpace(extra.Value)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// parseITunesDuration parses an <itunes:duration> value, given either as
+// seconds ("3600") or as clock time ("HH:MM:SS" or "MM:SS").
+func parseITunesDuration(raw string) (time.Duration, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, false
+	}
+
+	parts := strings.Split(raw, ":")
+	if len(parts) > 3 {
+		return 0, false
+	}
+
+	var seconds int
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, false
+		}
+		seconds = seconds*60 + n
+	}
+	if seconds == 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
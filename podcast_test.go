@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseITunesDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"3600":     time.Hour,
+		"90":       90 * time.Second,
+		"45:30":    45*time.Minute + 30*time.Second,
+		"01:02:03": time.Hour + 2*time.Minute + 3*time.Second,
+		" 1:00:00": time.Hour,
+	}
+	for raw, expected := range tests {
+		got, ok := parseITunesDuration(raw)
+		if !ok || got != expected {
+			t.Errorf("parseITunesDuration(%q) = %v, %v; expected %v", raw, got, ok, expected)
+		}
+	}
+
+	for _, raw := range []string{"", "0", "abc", "1:2:3:4", "-5", "1:-30"} {
+		if _, ok := parseITunesDuration(raw); ok {
+			t.Errorf("Expected parseITunesDuration(%q) to fail", raw)
+		}
+	}
+}
+
+func TestRSSToICalITunesDuration(t *testing.T) {
+	feed := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">
+  <channel>
+    <title>Podcast</title>
+    <item>
+      <title>Long Episode</title>
+      <guid>long</guid>
+      <pubDate>Sun, 27 Jul 2025 12:00:00 +0000</pubDate>
+      <itunes:duration>01:30:00</itunes:duration>
+    </item>
+    <item>
+      <title>Unknown Length</title>
+      <guid>unknown</guid>
+      <pubDate>Sun, 27 Jul 2025 15:00:00 +0000</pubDate>
+      <itunes:duration>later</itunes:duration>
+    </item>
+  </channel>
+</rss>`
+
+	rss, err := parseRSS([]byte(feed))
+	if err != nil {
+		t.Fatalf("Failed to parse feed: %v", err)
+	}
+	if got := rss.Channel.Items[0].ITunesDuration; got != "01:30:00" {
+		t.Errorf("Expected itunes:duration to be parsed, got '%s'", got)
+	}
+
+	ical, err := rssToICal(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+
+	// The episode length sets DTEND, and unparseable values use the default
+	for _, exp := range []string{"DTEND:20250727T133000Z", "DTEND:20250727T160000Z"} {
+		if !strings.Contains(ical, exp) {
+			t.Errorf("Expected iCal to contain '%s', got: %s", exp, ical)
+		}
+	}
+}
+
+func TestRSSToICalPodcastNamespace(t *testing.T) {
+	feed := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:podcast="https://podcastindex.org/namespace/1.0">
+  <channel>
+    <title>Podcast</title>
+    <item>
+      <title>Live From Austin</title>
+      <guid>austin</guid>
+      <pubDate>Sun, 27 Jul 2025 12:00:00 +0000</pubDate>
+      <podcast:location geo="geo:30.2672,-97.7431" osm="R113314">Austin, TX</podcast:location>
+      <podcast:person role="host">Jane Doe</podcast:person>
+      <podcast:person role="guest" href="https://example.com/john">John Smith</podcast:person>
+      <podcast:transcript url="https://example.com/austin.vtt" type="text/vtt"/>
+      <podcast:chapters url="https://example.com/austin.json" type="application/json+chapters"/>
+    </item>
+  </channel>
+</rss>`
+
+	rss, err := parseRSS([]byte(feed))
+	if err != nil {
+		t.Fatalf("Failed to parse feed: %v", err)
+	}
+
+	ical, err := rssToICal(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+	ical = unfoldICal(ical)
+
+	// Unknown podcast: tags such as transcript and chapters are ignored
+	for _, exp := range []string{
+		`LOCATION:Austin\, TX`,
+		"GEO:30.2672;-97.7431",
+		"CONTACT:Jane Doe",
+		"CONTACT:John Smith",
+	} {
+		if !strings.Contains(ical, exp) {
+			t.Errorf("Expected iCal to contain '%s', got: %s", exp, ical)
+		}
+	}
+}
+
+func TestPodcastPersonsLegacyNamespace(t *testing.T) {
+	item := Item{Extra: []ExtraElement{
+		{XMLName: xml.Name{Space: "https://github.com/Podcastindex-org/podcast-namespace/blob/main/docs/1.0.md", Local: "person"}, Value: " Jane Doe "},
+		{XMLName: xml.Name{Space: "https://podcastindex.org/namespace/1.0", Local: "person"}, Value: "Jane Doe"},
+		{XMLName: xml.Name{Space: "https://example.com/other", Local: "person"}, Value: "John Smith"},
+	}}
+	if got := item.podcastPersons(); len(got) != 1 || got[0] != "Jane Doe" {
+		t.Errorf("Expected only the podcast: person once, got %q", got)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// purgeToken is the shared secret /cache/purge requires as a bearer token.
+// When empty the endpoint is disabled.
+var purgeToken string
+
+// purgeHandler removes the cached calendars for the url parameter, or every
+// cached calendar when no url is given, so upstream changes show up without
+// waiting out the TTL.
+func purgeHandler(w http.ResponseWriter, r *http.Request) {
+	if purgeToken == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(purgeToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var purged int
+	if raw := r.URL.Query().Get("url"); raw != "" {
+		feedURL, err := normalizeFeedURL(raw)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		purged = cache.Delete(feedURL)
+		failures.Forget(feedURL)
+	} else {
+		purged = cache.Clear()
+	}
+
+	loggerFrom(r.Context()).Info("cache purged", "url", redactURL(r.URL.Query().Get("url")), "entries", purged)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"purged": purged})
+}
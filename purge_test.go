@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPurgeHandler(t *testing.T) {
+	defer func(token string) { purgeToken = token }(purgeToken)
+	purgeToken = "s3cret"
+
+	cache = &Cache{}
+	cache.Set("https://test.com/rss.xml", "feed")
+	cache.Set("https://other.com/rss.xml", "other")
+
+	for name, authorization := range map[string]string{
+		"missing": "",
+		"wrong":   "Bearer guess",
+		"scheme":  "Basic s3cret",
+	} {
+		req := httptest.NewRequest("POST", "/cache/purge", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		purgeHandler(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status code 401 for %s token, got %d", name, w.Code)
+		}
+	}
+	if _, ok := cache.Get("https://test.com/rss.xml"); !ok {
+		t.Fatal("Expected unauthorized requests to leave the cache alone")
+	}
+
+	req := httptest.NewRequest("POST", "/cache/purge?url=test.com/rss.xml", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	w := httptest.NewRecorder()
+	purgeHandler(w, req)
+
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"purged":1`) {
+		t.Errorf("Expected one entry purged, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, ok := cache.Get("https://test.com/rss.xml"); ok {
+		t.Error("Expected purged feed to be removed")
+	}
+	if _, ok := cache.Get("https://other.com/rss.xml"); !ok {
+		t.Error("Expected other feed to stay cached")
+	}
+
+	req = httptest.NewRequest("POST", "/cache/purge", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	w = httptest.NewRecorder()
+	purgeHandler(w, req)
+	if _, ok := cache.Get("https://other.com/rss.xml"); ok || !strings.Contains(w.Body.String(), `"purged":1`) {
+		t.Errorf("Expected purge without url to clear the cache, got: %s", w.Body.String())
+	}
+}
+
+func TestPurgeHandlerDisabled(t *testing.T) {
+	defer func(token string) { purgeToken = token }(purgeToken)
+	purgeToken = ""
+
+	req := httptest.NewRequest("POST", "/cache/purge", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	w := httptest.NewRecorder()
+	purgeHandler(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code 404 without PURGE_TOKEN, got %d", w.Code)
+	}
+
+	purgeToken = "s3cret"
+	req = httptest.NewRequest("GET", "/cache/purge", nil)
+	w = httptest.NewRecorder()
+	purgeHandler(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status code 405 for GET, got %d", w.Code)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// calendarParams are the query parameters /calendar understands. Anything
+// else following an unencoded url= value is taken to be part of the feed's
+// own query string.
+var calendarParams = map[string]bool{
+	"url": true, "b64url": true, "header": true, "format": true,
+	"duration": true, "allday": true, "tz": true, "html": true,
+	"datefrom": true, "limit": true, "sort": true, "alarm": true,
+	"contains": true, "excludes": true, "match": true, "matchfield": true,
+	"locationfield": true, "window": true, "after": true, "before": true,
+	"desc": true, "method": true, "uid_prefix": true, "prodid": true,
+	"name": true, "paginate": true, "maxpages": true, "busy": true,
+	"status": true, "upcoming": true, "strict": true,
+	"discover": true, "spread": true, "at": true, "errorsInline": true,
+}
+
+// calendarQuery parses a /calendar query string. Unlike url.ParseQuery it
+// tolerates feed URLs pasted without encoding: parameters after url= that
+// /calendar doesn't know are put back into that feed URL, so
+// ?url=https://example.com/feed?a=1&b=2 asks for the whole feed URL rather
+// than one truncated at the first "&". Each b64url= value is decoded and
+// added as a url= value.
+func calendarQuery(raw string) (url.Values, error) {
+	query := url.Values{}
+	inURL := false
+	for _, part := range strings.Split(raw, "&") {
+		if part == "" {
+			continue
+		}
+		rawKey, rawValue, _ := strings.Cut(part, "=")
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			return nil, errors.New("invalid query string")
+		}
+
+		if inURL && !calendarParams[key] {
+			urls := query["url"]
+			urls[len(urls)-1] += "&" + part
+			continue
+		}
+
+		value, err := url.QueryUnescape(rawValue)
+		if err != nil {
+			return nil, errors.New("invalid query string")
+		}
+		switch key {
+		case "url":
+			query.Add("url", unescapeFeedURL(value))
+			inURL = true
+			continue
+		case "b64url":
+			decoded, err := decodeBase64URL(value)
+			if err != nil {
+				return nil, err
+			}
+			query.Add("url", decoded)
+		default:
+			query.Add(key, value)
+		}
+		inURL = false
+	}
+	return query, nil
+}
+
+// unescapeFeedURL undoes extra rounds of percent-encoding, for URLs that
+// were encoded once more than needed (https%3A%2F%2F...).
+func unescapeFeedURL(value string) string {
+	for i := 0; i < 2 && isEscapedURL(value); i++ {
+		unescaped, err := url.QueryUnescape(value)
+		if err != nil {
+			break
+		}
+		value = unescaped
+	}
+	return value
+}
+
+func isEscapedURL(value string) bool {
+	lower := strings.ToLower(value)
+	return strings.HasPrefix(lower, "http%3a") || strings.HasPrefix(lower, "https%3a")
+}
+
+// decodeBase64URL decodes a b64url= value, accepting the URL-safe and
+// standard alphabets with or without padding. A "+" left unencoded arrives
+// as a space and is restored.
+func decodeBase64URL(value string) (string, error) {
+	value = strings.TrimRight(strings.ReplaceAll(strings.TrimSpace(value), " ", "+"), "=")
+	for _, encoding := range []*base64.Encoding{base64.RawURLEncoding, base64.RawStdEncoding} {
+		if decoded, err := encoding.DecodeString(value); err == nil {
+			return strings.TrimSpace(string(decoded)), nil
+		}
+	}
+	return "", errors.New("invalid b64url: expected a base64-encoded feed URL")
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestCalendarQuery(t *testing.T) {
+	feed := "https://example.com/feed?a=1&b=2"
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"encoded", "url=" + url.QueryEscape(feed) + "&duration=30m"},
+		{"unencoded", "url=" + feed + "&duration=30m"},
+		{"double encoded", "url=" + url.QueryEscape(url.QueryEscape(feed)) + "&duration=30m"},
+		{"url last", "duration=30m&url=" + feed},
+		{"b64url", "b64url=" + base64.RawURLEncoding.EncodeToString([]byte(feed)) + "&duration=30m"},
+		{"b64url padded", "b64url=" + base64.StdEncoding.EncodeToString([]byte(feed)) + "&duration=30m"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := calendarQuery(tt.raw)
+			if err != nil {
+				t.Fatalf("Failed to parse query: %v", err)
+			}
+			if got := query["url"]; len(got) != 1 || got[0] != feed {
+				t.Errorf("Expected url %q, got %q", feed, got)
+			}
+			if got := query.Get("duration"); got != "30m" {
+				t.Errorf("Expected duration 30m, got %q", got)
+			}
+		})
+	}
+}
+
+func TestCalendarQueryInvalidB64URL(t *testing.T) {
+	if _, err := calendarQuery("b64url=not*base64"); err == nil {
+		t.Error("Expected an error for an invalid b64url value")
+	}
+}
+
+func TestCalendarHandlerFeedURLWithQuery(t *testing.T) {
+	var received url.Values
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.URL.Query()
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(mockRSSFeed))
+	}))
+	defer mockServer.Close()
+
+	feed := mockServer.URL + "/feed?category=events&page=2"
+	for _, target := range []string{
+		"/calendar?url=" + feed + "&duration=2h",
+		"/calendar?b64url=" + base64.URLEncoding.EncodeToString([]byte(feed)),
+	} {
+		cache = &Cache{}
+		failures = &NegativeCache{}
+		received = nil
+
+		req := httptest.NewRequest("GET", target, nil)
+		w := httptest.NewRecorder()
+		calendarHandler(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status code 200, got %d: %s", target, w.Code, w.Body.String())
+		}
+		if received.Get("category") != "events" || received.Get("page") != "2" {
+			t.Errorf("%s: expected the feed's own query to reach upstream, got %v", target, received)
+		}
+		if received.Has("duration") {
+			t.Errorf("%s: expected duration to stay a calendar option, got %v", target, received)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// rateLimiterIdle is how long a client's limiter is kept after its last
+// request. By then its bucket has long refilled, so dropping it is harmless.
+const rateLimiterIdle = 10 * time.Minute
+
+// clientLimits throttles requests per client IP. It is nil, and requests are
+// not limited, unless RATE_LIMIT is set.
+var clientLimits *rateLimiter
+
+// rateLimiter hands out a token bucket per client IP allowing perMinute
+// requests a minute, with bursts of up to perMinute.
+type rateLimiter struct {
+	perMinute int
+
+	mu        sync.Mutex
+	clients   map[string]*clientLimiter
+	lastSweep time.Time
+}
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	return &rateLimiter{
+		perMinute: perMinute,
+		clients:   make(map[string]*clientLimiter),
+		lastSweep: time.Now(),
+	}
+}
+
+// allow reports whether ip may make a request now, and if not how long it
+// should wait before trying again.
+func (l *rateLimiter) allow(ip string) (bool, time.Duration) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= rateLimiterIdle {
+		l.sweep(now)
+	}
+	client, ok := l.clients[ip]
+	if !ok {
+		client = &clientLimiter{limiter: rate.NewLimiter(rate.Every(time.Minute/time.Duration(l.perMinute)), l.perMinute)}
+		l.clients[ip] = client
+	}
+	client.lastSeen = now
+
+	reservation := client.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// sweep drops the limiters of clients idle for rateLimiterIdle. The caller
+// holds l.mu.
+func (l *rateLimiter) sweep(now time.Time) {
+	for ip, client := range l.clients {
+		if now.Sub(client.lastSeen) >= rateLimiterIdle {
+			delete(l.clients, ip)
+		}
+	}
+	l.lastSweep = now
+}
+
+// limitRate wraps next so clients over RATE_LIMIT get 429 Too Many Requests
+// with a Retry-After header.
+func limitRate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if clientLimits == nil {
+			next(w, r)
+			return
+		}
+		if ok, delay := clientLimits.allow(clientIP(r)); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLimitRate(t *testing.T) {
+	defer func(l *rateLimiter) { clientLimits = l }(clientLimits)
+	clientLimits = newRateLimiter(2)
+
+	handler := limitRate(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/calendar?url=https://test.com/rss.xml", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := request("203.0.113.7:1234"); w.Code != http.StatusOK {
+			t.Fatalf("Expected request %d to be allowed, got %d", i+1, w.Code)
+		}
+	}
+
+	w := request("203.0.113.7:5678")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status code 429, got %d", w.Code)
+	}
+	retry, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	if err != nil || retry < 1 || retry > 30 {
+		t.Errorf("Expected Retry-After around 30 seconds, got %q", w.Header().Get("Retry-After"))
+	}
+
+	if w := request("198.51.100.1:1234"); w.Code != http.StatusOK {
+		t.Errorf("Expected another client to be allowed, got %d", w.Code)
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	limiter := newRateLimiter(1)
+	limiter.allow("203.0.113.7")
+	limiter.clients["203.0.113.7"].lastSeen = time.Now().Add(-rateLimiterIdle)
+	limiter.lastSweep = time.Now().Add(-rateLimiterIdle)
+
+	if ok, _ := limiter.allow("198.51.100.1"); !ok {
+		t.Fatal("Expected a new client to be allowed")
+	}
+	if _, ok := limiter.clients["203.0.113.7"]; ok {
+		t.Error("Expected the idle client's limiter to be dropped")
+	}
+}
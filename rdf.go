@@ -0,0 +1,51 @@
+package main
+
+import "encoding/xml"
+
+// RDF is an RSS 1.0 feed document, where items are siblings of the channel
+// rather than nested inside it.
+type RDF struct {
+	XMLName xml.Name   `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# RDF"`
+	Channel RDFChannel `xml:"channel"`
+	Items   []RDFItem  `xml:"item"`
+}
+
+type RDFChannel struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+}
+
+type RDFItem struct {
+	About       string `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	DCDate      string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	DCCreator   string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+}
+
+// toRSS normalizes an RSS 1.0 feed into the RSS representation used by
+// rssToICal. The rdf:about URI identifies each item.
+func (r *RDF) toRSS() *RSS {
+	rss := &RSS{
+		Channel: Channel{
+			Title:       r.Channel.Title,
+			Link:        r.Channel.Link,
+			Description: r.Channel.Description,
+		},
+	}
+
+	for _, item := range r.Items {
+		rss.Channel.Items = append(rss.Channel.Items, Item{
+			Title:       item.Title,
+			Description: item.Description,
+			Link:        item.Link,
+			DCDate:      item.DCDate,
+			DCCreator:   item.DCCreator,
+			GUID:        item.About,
+		})
+	}
+
+	return rss
+}
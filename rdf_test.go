@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Mock RSS 1.0 feed for testing, after the sample in the RSS 1.0 spec
+const mockRDFFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rdf:RDF
+  xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+  xmlns:dc="http://purl.org/dc/elements/1.1/"
+  xmlns="http://purl.org/rss/1.0/">
+  <channel rdf:about="https://example.org/news.rdf">
+    <title>Example Research News</title>
+    <link>https://example.org/</link>
+    <description>Announcements from the research office</description>
+    <items>
+      <rdf:Seq>
+        <rdf:li resource="https://example.org/news/1"/>
+        <rdf:li resource="https://example.org/news/2"/>
+      </rdf:Seq>
+    </items>
+  </channel>
+  <item rdf:about="https://example.org/news/1">
+    <title>Grant Deadline</title>
+    <link>https://example.org/news/1</link>
+    <description>Applications close soon</description>
+    <dc:date>2025-07-27T12:00:00Z</dc:date>
+  </item>
+  <item rdf:about="https://example.org/news/2">
+    <title>Seminar Series</title>
+    <link>https://example.org/news/2</link>
+    <description>Weekly seminars resume</description>
+    <dc:date>2025-07-28T09:30:00+02:00</dc:date>
+  </item>
+</rdf:RDF>`
+
+func TestParseRSSRDF(t *testing.T) {
+	rss, err := parseRSS([]byte(mockRDFFeed))
+	if err != nil {
+		t.Fatalf("Failed to parse RSS 1.0 feed: %v", err)
+	}
+
+	if rss.Channel.Title != "Example Research News" {
+		t.Errorf("Expected channel title 'Example Research News', got '%s'", rss.Channel.Title)
+	}
+	if len(rss.Channel.Items) != 2 {
+		t.Fatalf("Expected 2 items, got %d", len(rss.Channel.Items))
+	}
+
+	first := rss.Channel.Items[0]
+	if first.GUID != "https://example.org/news/1" {
+		t.Errorf("Expected rdf:about as GUID, got '%s'", first.GUID)
+	}
+	if first.DCDate != "2025-07-27T12:00:00Z" {
+		t.Errorf("Expected dc:date, got '%s'", first.DCDate)
+	}
+}
+
+func TestRDFToICal(t *testing.T) {
+	rss, err := parseRSS([]byte(mockRDFFeed))
+	if err != nil {
+		t.Fatalf("Failed to parse RSS 1.0 feed: %v", err)
+	}
+
+	ical, err := rssToICal(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS 1.0 to iCal: %v", err)
+	}
+
+	if count := strings.Count(ical, "BEGIN:VEVENT"); count != 2 {
+		t.Errorf("Expected 2 VEVENTs, got %d", count)
+	}
+
+	expected := []string{
+		"NAME:Example Research News",
+		"UID:https://example.org/news/1",
+		"SUMMARY:Grant Deadline",
+		"DTSTART:20250727T120000Z",
+		"DTSTART:20250728T073000Z",
+	}
+	for _, exp := range expected {
+		if !strings.Contains(ical, exp) {
+			t.Errorf("Expected iCal to contain '%s', got: %s", exp, ical)
+		}
+	}
+}
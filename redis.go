@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	cacheBackendMemory = "memory"
+	cacheBackendRedis  = "redis"
+)
+
+const (
+	// redisKeyPrefix namespaces cached calendars in a shared Redis database.
+	// Each is a hash named by the SHA-256 of its cache key, since the key can
+	// carry feed passwords and forwarded header values.
+	redisKeyPrefix = "rss2ical:entry:"
+	// redisStaleLifetime is how long Redis keeps an entry past its TTL, so
+	// it can still be revalidated upstream and keep its event versions.
+	redisStaleLifetime = 24 * time.Hour
+	// redisTimeout bounds each read and write; a slow Redis shouldn't hold
+	// a request up longer than fetching the feed would.
+	redisTimeout = 2 * time.Second
+	// redisPurgeTimeout bounds scanning for the keys to purge.
+	redisPurgeTimeout = 30 * time.Second
+)
+
+// RedisCache is a CacheBackend storing entries as JSON in Redis, alongside
+// the entry's feeds for purges.
+type RedisCache struct {
+	client *redis.Client
+}
+
+// newRedisCache connects lazily to the Redis server at a redis:// or
+// rediss:// URL.
+func newRedisCache(rawURL string) (*RedisCache, error) {
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis_url: %w", err)
+	}
+	return &RedisCache{client: redis.NewClient(opts)}, nil
+}
+
+// Ping checks that the server is reachable.
+func (c *RedisCache) Ping(ctx context.Context) error {
+	return c.client.Ping(ctx).Err()
+}
+
+func (c *RedisCache) Get(key string) (CacheEntry, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	data, err := c.client.HGet(ctx, redisKey(key), "entry").Bytes()
+	if errors.Is(err, redis.Nil) {
+		return CacheEntry{}, false, nil
+	}
+	if err != nil {
+		return CacheEntry{}, false, err
+	}
+	entry, err := decodeCacheEntry(data)
+	if err != nil {
+		return CacheEntry{}, false, err
+	}
+	return entry, true, nil
+}
+
+func (c *RedisCache) Set(key string, entry CacheEntry) error {
+	data, err := encodeCacheEntry(entry)
+	if err != nil {
+		return err
+	}
+	ttl := entry.ttl
+	if ttl <= 0 {
+		ttl = cacheTTL
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	name := redisKey(key)
+	_, err = c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, name, "entry", data, "feeds", keyFeeds(key))
+		pipe.Expire(ctx, name, ttl+redisStaleLifetime)
+		return nil
+	})
+	return err
+}
+
+func (c *RedisCache) Delete(match func(feeds string) bool) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisPurgeTimeout)
+	defer cancel()
+
+	var names []string
+	iter := c.client.Scan(ctx, 0, redisKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		names = append(names, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return 0, err
+	}
+	if len(names) == 0 {
+		return 0, nil
+	}
+
+	feeds := make([]*redis.StringCmd, len(names))
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, name := range names {
+			feeds[i] = pipe.HGet(ctx, name, "feeds")
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return 0, err
+	}
+	var keys []string
+	for i, name := range names {
+		// An entry that expired since the scan has nothing to delete
+		if value, err := feeds[i].Result(); err == nil && match(value) {
+			keys = append(keys, name)
+		}
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	removed, err := c.client.Del(ctx, keys...).Result()
+	return int(removed), err
+}
+
+// redisKey names the Redis hash holding the entry for a cache key.
+func redisKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return redisKeyPrefix + hex.EncodeToString(sum[:])
+}
+
+// storedEntry is the JSON form of a CacheEntry.
+type storedEntry struct {
+	Data       string                   `json:"data"`
+	Timestamp  time.Time                `json:"timestamp"`
+	Generated  time.Time                `json:"generated"`
+	ETag       string                   `json:"etag,omitempty"`
+	Validators Validators               `json:"validators"`
+	Versions   map[string]storedVersion `json:"versions,omitempty"`
+	Events     int                      `json:"events"`
+	Skipped    int                      `json:"skipped,omitempty"`
+	TTL        time.Duration            `json:"ttl,omitempty"`
+}
+
+type storedVersion struct {
+	Hash     string    `json:"hash"`
+	Sequence int       `json:"sequence"`
+	Stamp    time.Time `json:"stamp"`
+}
+
+func encodeCacheEntry(entry CacheEntry) ([]byte, error) {
+	stored := storedEntry{
+		Data:       entry.data,
+		Timestamp:  entry.timestamp,
+		Generated:  entry.generated,
+		ETag:       entry.etag,
+		Validators: entry.validators,
+		Events:     entry.events,
+		Skipped:    entry.skipped,
+		TTL:        entry.ttl,
+	}
+	if len(entry.versions) > 0 {
+		stored.Versions = make(map[string]storedVersion, len(entry.versions))
+		for uid, version := range entry.versions {
+			stored.Versions[uid] = storedVersion{Hash: version.hash, Sequence: version.sequence, Stamp: version.stamp}
+		}
+	}
+	return json.Marshal(stored)
+}
+
+func decodeCacheEntry(data []byte) (CacheEntry, error) {
+	var stored storedEntry
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return CacheEntry{}, fmt.Errorf("invalid cache entry: %w", err)
+	}
+	entry := CacheEntry{
+		data:       stored.Data,
+		timestamp:  stored.Timestamp,
+		generated:  stored.Generated,
+		etag:       stored.ETag,
+		validators: stored.Validators,
+		events:     stored.Events,
+		skipped:    stored.Skipped,
+		ttl:        stored.TTL,
+	}
+	if len(stored.Versions) > 0 {
+		entry.versions = make(eventVersions, len(stored.Versions))
+		for uid, version := range stored.Versions {
+			entry.versions[uid] = eventVersion{hash: version.Hash, sequence: version.Sequence, stamp: version.Stamp}
+		}
+	}
+	return entry, nil
+}
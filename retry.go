@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultFetchRetries = 2
+
+	// maxRetryAfter caps how long a Retry-After header can delay a retry,
+	// since the client is waiting on the response.
+	maxRetryAfter = 10 * time.Second
+)
+
+// fetchRetries is how many times a fetch is retried after a network error or
+// 5xx response. 4xx responses are never retried.
+var fetchRetries = defaultFetchRetries
+
+// retryBackoff is the delay before the first retry; it doubles with each
+// subsequent attempt.
+var retryBackoff = 500 * time.Millisecond
+
+// retryableError marks a fetch failure that may succeed if tried again.
+type retryableError struct {
+	err error
+	// retryAfter is the upstream's requested delay, if it sent one
+	retryAfter time.Duration
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+
+func (e *retryableError) Unwrap() error { return e.err }
+
+// transientNetworkError reports whether err is a network failure, such as a
+// refused connection or timeout, rather than one we caused deliberately.
+func transientNetworkError(err error) bool {
+	if errors.Is(err, errPrivateAddress) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	// *url.Error is itself a net.Error, so look at what it wraps; redirect
+	// policy errors are not network failures
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// retryDelay returns how long to wait before retrying after the given
+// zero-based attempt, preferring the upstream's Retry-After when present.
+func retryDelay(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return min(retryAfter, maxRetryAfter)
+	}
+	return retryBackoff << attempt
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as
+// an HTTP date. It returns zero when the header is absent or invalid.
+func parseRetryAfter(raw string) time.Duration {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(raw); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(raw); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
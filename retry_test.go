@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetchRSSRetriesTransientFailure(t *testing.T) {
+	var attempts int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(mockRSSFeed))
+	}))
+	defer mockServer.Close()
+
+	rss, err := fetchRSS(context.Background(), mockServer.URL)
+	if err != nil {
+		t.Fatalf("Expected retry to succeed, got: %v", err)
+	}
+	if rss.Channel.Title != "Test RSS Feed" {
+		t.Errorf("Unexpected channel title '%s'", rss.Channel.Title)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts)
+	}
+}
+
+func TestFetchRSSGivesUpAfterRetries(t *testing.T) {
+	var attempts int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer mockServer.Close()
+
+	if _, err := fetchRSS(context.Background(), mockServer.URL); err == nil {
+		t.Fatal("Expected error from persistently failing feed")
+	}
+	if want := int32(fetchRetries + 1); attempts != want {
+		t.Errorf("Expected %d attempts, got %d", want, attempts)
+	}
+}
+
+func TestFetchRSSNoRetryOnClientError(t *testing.T) {
+	var attempts int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer mockServer.Close()
+
+	if _, err := fetchRSS(context.Background(), mockServer.URL); err == nil {
+		t.Fatal("Expected error for 404 feed")
+	}
+	if attempts != 1 {
+		t.Errorf("Expected a single attempt for 4xx, got %d", attempts)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	defer func(d time.Duration) { retryBackoff = d }(retryBackoff)
+	retryBackoff = 100 * time.Millisecond
+
+	if got := retryDelay(0, 0); got != 100*time.Millisecond {
+		t.Errorf("Expected first backoff 100ms, got %v", got)
+	}
+	if got := retryDelay(2, 0); got != 400*time.Millisecond {
+		t.Errorf("Expected third backoff 400ms, got %v", got)
+	}
+	if got := retryDelay(0, 3*time.Second); got != 3*time.Second {
+		t.Errorf("Expected Retry-After delay 3s, got %v", got)
+	}
+	if got := retryDelay(0, time.Hour); got != maxRetryAfter {
+		t.Errorf("Expected Retry-After to be capped at %v, got %v", maxRetryAfter, got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if got := parseRetryAfter("5"); got != 5*time.Second {
+		t.Errorf("Expected 5s, got %v", got)
+	}
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(date); got <= 0 || got > time.Minute {
+		t.Errorf("Expected delay up to 1m for HTTP date, got %v", got)
+	}
+	for _, raw := range []string{"", "soon", "-1"} {
+		if got := parseRetryAfter(raw); got != 0 {
+			t.Errorf("Expected no delay for %q, got %v", raw, got)
+		}
+	}
+}
+
+func TestTransientNetworkError(t *testing.T) {
+	refused := &url.Error{Op: "Get", URL: "http://example.com", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}
+	if !transientNetworkError(refused) {
+		t.Error("Expected refused connection to be retried")
+	}
+
+	redirects := &url.Error{Op: "Get", URL: "http://example.com", Err: errors.New("stopped after 10 redirects")}
+	if transientNetworkError(redirects) {
+		t.Error("Expected redirect limit not to be retried")
+	}
+
+	private := &url.Error{Op: "Get", URL: "http://example.com", Err: &net.OpError{Op: "dial", Err: errPrivateAddress}}
+	if transientNetworkError(private) {
+		t.Error("Expected blocked address not to be retried")
+	}
+}
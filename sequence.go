@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// eventVersion records an event's content as last served, so a change can
+// be announced by bumping SEQUENCE.
+type eventVersion struct {
+	hash     string
+	sequence int
+	// stamp is when this version was first generated, used as DTSTAMP so
+	// an unchanged event serializes the same way every time
+	stamp time.Time
+}
+
+// eventVersions maps event UIDs to their versions.
+type eventVersions map[string]eventVersion
+
+// next returns the version of an event with the given UID and content
+// hash: unchanged events keep their previous version, changed ones get the
+// next sequence number and new ones start at zero.
+func (v eventVersions) next(uid, hash string, now time.Time) eventVersion {
+	previous, seen := v[uid]
+	switch {
+	case !seen:
+		return eventVersion{hash: hash, stamp: now}
+	case previous.hash == hash:
+		return previous
+	default:
+		return eventVersion{hash: hash, sequence: previous.sequence + 1, stamp: now}
+	}
+}
+
+// contentHash fingerprints the parts of an item that end up in its event.
+// Times are left out for undated items, whose start is the conversion time.
+func (i scheduledItem) contentHash(opts CalendarOptions) string {
+	parts := []string{
+		i.Title,
+		i.eventDescription,
+		i.Link,
+		i.location(opts.LocationField),
+		strings.Join(i.categories(), listSeparator),
+	}
+	if i.dated {
+		parts = append(parts, i.startTime.UTC().Format(time.RFC3339), i.endTime.UTC().Format(time.RFC3339))
+	}
+
+	sum := sha1.Sum([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalendarHandlerSequence(t *testing.T) {
+	description := "First draft"
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+  <channel>
+    <title>Versions</title>
+    <item>
+      <title>Changing</title>
+      <description>%s</description>
+      <pubDate>Mon, 01 Jan 2024 10:00:00 +0000</pubDate>
+      <guid>changing</guid>
+    </item>
+    <item>
+      <title>Stable</title>
+      <description>Never edited</description>
+      <pubDate>Tue, 02 Jan 2024 10:00:00 +0000</pubDate>
+      <guid>stable</guid>
+    </item>
+  </channel>
+</rss>`, description)
+	}))
+	defer mockServer.Close()
+
+	defer func(ttl time.Duration) { cacheTTL = ttl }(cacheTTL)
+	cacheTTL = time.Nanosecond
+
+	// Clear cache for clean test
+	cache = &Cache{}
+	failures = &NegativeCache{}
+
+	fetch := func() map[string]string {
+		req := httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil)
+		w := httptest.NewRecorder()
+		calendarHandler(w, req)
+		return eventProperties(t, w.Body.String())
+	}
+
+	first := fetch()
+	if first["changing SEQUENCE"] != "0" || first["stable SEQUENCE"] != "0" {
+		t.Errorf("Expected new events at SEQUENCE 0, got %v", first)
+	}
+	if _, err := time.Parse("20060102T150405Z", first["changing DTSTAMP"]); err != nil {
+		t.Errorf("Expected a UTC DTSTAMP, got %q", first["changing DTSTAMP"])
+	}
+
+	time.Sleep(time.Millisecond)
+	if unchanged := fetch(); unchanged["changing SEQUENCE"] != "0" || unchanged["changing DTSTAMP"] != first["changing DTSTAMP"] {
+		t.Errorf("Expected an unchanged event to keep its version, got %v", unchanged)
+	}
+
+	description = "Second draft"
+	time.Sleep(time.Millisecond)
+	second := fetch()
+	if second["changing SEQUENCE"] != "1" {
+		t.Errorf("Expected edited event at SEQUENCE 1, got %v", second)
+	}
+	if second["stable SEQUENCE"] != "0" {
+		t.Errorf("Expected unedited event to stay at SEQUENCE 0, got %v", second)
+	}
+}
+
+// eventProperties indexes the SEQUENCE and DTSTAMP of each event by
+// "<uid> <property>".
+func eventProperties(t *testing.T, ical string) map[string]string {
+	t.Helper()
+	properties := make(map[string]string)
+	for _, event := range strings.Split(ical, "BEGIN:VEVENT")[1:] {
+		var uid string
+		values := make(map[string]string)
+		for _, line := range strings.Split(event, "\r\n") {
+			name, value, _ := strings.Cut(line, ":")
+			switch name {
+			case "UID":
+				uid = value
+			case "SEQUENCE", "DTSTAMP":
+				values[name] = value
+			}
+		}
+		for name, value := range values {
+			properties[uid+" "+name] = value
+		}
+	}
+	return properties
+}
+
+func TestEventVersionsNext(t *testing.T) {
+	then := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := then.Add(time.Hour)
+	previous := eventVersions{"a": {hash: "h1", sequence: 2, stamp: then}}
+
+	if v := previous.next("a", "h1", now); v.sequence != 2 || !v.stamp.Equal(then) {
+		t.Errorf("Expected unchanged version to be kept, got %+v", v)
+	}
+	if v := previous.next("a", "h2", now); v.sequence != 3 || !v.stamp.Equal(now) {
+		t.Errorf("Expected changed version to bump SEQUENCE, got %+v", v)
+	}
+	if v := previous.next("b", "h1", now); v.sequence != 0 || !v.stamp.Equal(now) {
+		t.Errorf("Expected new event at SEQUENCE 0, got %+v", v)
+	}
+}
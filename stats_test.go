@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatsHandler(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(mockRSSFeed))
+	}))
+	defer mockServer.Close()
+
+	// Clear cache for clean test
+	cache = &Cache{}
+	failures = &NegativeCache{}
+
+	// A miss that fetches and stores, then a hit
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil)
+		calendarHandler(httptest.NewRecorder(), req)
+	}
+
+	req := httptest.NewRequest("GET", "/cache/stats", nil)
+	w := httptest.NewRecorder()
+	statsHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", w.Code)
+	}
+	var stats CacheStats
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("Expected JSON stats, got %q: %v", w.Body.String(), err)
+	}
+	if stats.Entries != 1 || stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Expected 1 entry, 1 hit and 1 miss, got %+v", stats)
+	}
+	if stats.OldestAge < stats.NewestAge || stats.NewestAge < 0 {
+		t.Errorf("Expected sensible entry ages, got %+v", stats)
+	}
+}
+
+func TestCacheStatsEmpty(t *testing.T) {
+	stats := (&Cache{}).Stats()
+	if stats != (CacheStats{}) {
+		t.Errorf("Expected zero stats for an empty cache, got %+v", stats)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+var subscribeTemplate = template.Must(template.New("subscribe").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Subscribe - RSS2ICal</title>
+    <style>
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif;
+            max-width: 800px;
+            margin: 0 auto;
+            padding: 2rem;
+            line-height: 1.6;
+            color: #333;
+        }
+        .container {
+            background: #f8f9fa;
+            border-radius: 8px;
+            padding: 2rem;
+        }
+        .subscribe {
+            display: inline-block;
+            background: #007bff;
+            color: white;
+            padding: 0.75rem 1.5rem;
+            border-radius: 4px;
+            text-decoration: none;
+            font-size: 1rem;
+        }
+        .url-output {
+            background: white;
+            padding: 0.75rem;
+            border-radius: 4px;
+            word-break: break-all;
+            font-family: monospace;
+            margin: 1rem 0 0.5rem;
+        }
+        .copy-btn {
+            background: #28a745;
+            color: white;
+            border: none;
+            border-radius: 4px;
+            padding: 0.5rem 1rem;
+            font-size: 0.875rem;
+            cursor: pointer;
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <h1>Subscribe to this calendar</h1>
+        <p>Events from <code>{{.Feed}}</code></p>
+        <a class="subscribe" href="{{.Webcal}}">Subscribe in your calendar app</a>
+        <div id="calendarUrl" class="url-output">{{.Webcal}}</div>
+        <button id="copyBtn" class="copy-btn">Copy URL</button>
+    </div>
+
+    <script>
+        document.getElementById('copyBtn').addEventListener('click', function() {
+            const btn = this;
+            navigator.clipboard.writeText(document.getElementById('calendarUrl').textContent).then(function() {
+                btn.textContent = 'Copied!';
+                setTimeout(function() { btn.textContent = 'Copy URL'; }, 2000);
+            });
+        });
+    </script>
+</body>
+</html>
+`))
+
+// subscribeHandler serves a page with a one-click webcal:// subscription
+// link to /calendar for the feeds and options in the query, for sharing.
+func subscribeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query, err := calendarQuery(r.URL.RawQuery)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	urls := feedURLs(query["url"])
+	if len(urls) == 0 {
+		http.Error(w, "RSS URL required: use ?url=... parameter", http.StatusBadRequest)
+		return
+	}
+	feeds := make([]string, len(urls))
+	for i, rssURL := range urls {
+		normalized, err := normalizeFeedURL(rssURL)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !hostAllowed(normalized) {
+			http.Error(w, "Feed host not allowed", http.StatusForbidden)
+			return
+		}
+		urls[i] = normalized
+		feeds[i] = redactURL(normalized)
+	}
+	// Catch bad options here rather than in the subscriber's calendar app
+	if _, err := parseCalendarOptions(query); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query["url"] = urls
+	query.Del("b64url")
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	subscribeTemplate.Execute(w, struct {
+		Feed   string
+		Webcal template.URL
+	}{
+		Feed: strings.Join(feeds, ", "),
+		// Built from a validated feed URL and encoded query, so it is safe
+		// to use in an href despite html/template not knowing the scheme
+		Webcal: template.URL("webcal://" + r.Host + "/calendar?" + query.Encode()),
+	})
+}
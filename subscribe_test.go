@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSubscribeHandler(t *testing.T) {
+	feed := "https://example.com/feed?a=1&b=2"
+	req := httptest.NewRequest("GET", "/subscribe?url="+url.QueryEscape(feed)+"&duration=2h", nil)
+	req.Host = "cal.example.org"
+	w := httptest.NewRecorder()
+	subscribeHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d: %s", w.Code, w.Body.String())
+	}
+	want := "webcal://cal.example.org/calendar?duration=2h&amp;url=" + url.QueryEscape(feed)
+	if body := w.Body.String(); !strings.Contains(body, `href="`+want+`"`) {
+		t.Errorf("Expected a webcal link %q, got:\n%s", want, body)
+	}
+}
+
+func TestSubscribeHandlerInvalid(t *testing.T) {
+	for target, status := range map[string]int{
+		"/subscribe":                                           http.StatusBadRequest,
+		"/subscribe?url=ftp://example.com/feed":                http.StatusBadRequest,
+		"/subscribe?url=https://example.com/feed&duration=bad": http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		subscribeHandler(w, httptest.NewRequest("GET", target, nil))
+		if w.Code != status {
+			t.Errorf("%s: expected status code %d, got %d", target, status, w.Code)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestCalendarHandlerSpans(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(mockRSSFeed))
+	}))
+	defer mockServer.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	defer func(t trace.Tracer) { tracer = t }(tracer)
+	tracer = provider.Tracer("test")
+	defer func(p propagation.TextMapPropagator) { otel.SetTextMapPropagator(p) }(otel.GetTextMapPropagator())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	cache = &Cache{}
+	failures = &NegativeCache{}
+
+	req := httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	calendarHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", w.Code)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("Expected 3 spans, got %d", len(spans))
+	}
+	byName := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range spans {
+		byName[span.Name()] = span
+	}
+	root, fetch, convert := byName["calendar"], byName["fetch feed"], byName["convert feed"]
+	if root == nil || fetch == nil || convert == nil {
+		t.Fatalf("Expected calendar, fetch feed and convert feed spans, got %v", byName)
+	}
+
+	if got := root.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected the incoming trace to be continued, got trace %s", got)
+	}
+	for _, child := range []sdktrace.ReadOnlySpan{fetch, convert} {
+		if child.Parent().SpanID() != root.SpanContext().SpanID() {
+			t.Errorf("Expected %s to be a child of the request span", child.Name())
+		}
+	}
+
+	attributes := make(map[string]string)
+	for _, attr := range append(fetch.Attributes(), convert.Attributes()...) {
+		attributes[string(attr.Key)] = attr.Value.Emit()
+	}
+	if attributes["feed.host"] != "127.0.0.1" {
+		t.Errorf("Expected feed.host 127.0.0.1, got %q", attributes["feed.host"])
+	}
+	if attributes["feed.items"] != "2" {
+		t.Errorf("Expected feed.items 2, got %q", attributes["feed.items"])
+	}
+
+	// A cached response involves no fetch or conversion
+	recorder = tracetest.NewSpanRecorder()
+	tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+	calendarHandler(httptest.NewRecorder(), httptest.NewRequest("GET", "/calendar?url="+mockServer.URL, nil))
+	if n := len(recorder.Ended()); n != 1 {
+		t.Errorf("Expected 1 span for a cached request, got %d", n)
+	}
+}
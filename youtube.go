@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// youtubeWatchURL is the canonical page of a YouTube video, by ID.
+const youtubeWatchURL = "https://www.youtube.com/watch?v="
+
+// youtubeItem adjusts an item converted from a YouTube channel feed entry:
+// the title comes from the entry's <media:group>, as does the description
+// since the entry has no summary, and the watch page is the event URL and
+// LOCATION.
+func (e *AtomEntry) youtubeItem(item Item) Item {
+	for _, group := range e.Groups {
+		if title := strings.TrimSpace(group.Title); title != "" {
+			item.Title = title
+		}
+		if description := strings.TrimSpace(group.Description); description != "" && item.Description == "" {
+			item.Description = description
+		}
+	}
+
+	watch := youtubeWatchURL + url.QueryEscape(strings.TrimSpace(e.VideoID))
+	item.Link = watch
+	item.Location = watch
+	return item
+}
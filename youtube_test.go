@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const mockYouTubeFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns:yt="http://www.youtube.com/xml/schemas/2015" xmlns:media="http://search.yahoo.com/mrss/" xmlns="http://www.w3.org/2005/Atom">
+ <link rel="self" href="https://www.youtube.com/feeds/videos.xml?channel_id=UC123"/>
+ <id>yt:channel:UC123</id>
+ <yt:channelId>UC123</yt:channelId>
+ <title>Example Channel</title>
+ <entry>
+  <id>yt:video:abc123</id>
+  <yt:videoId>abc123</yt:videoId>
+  <yt:channelId>UC123</yt:channelId>
+  <title>First Video</title>
+  <link rel="alternate" href="https://www.youtube.com/watch?v=abc123&amp;feature=youtu.be"/>
+  <published>2024-01-01T12:00:00+00:00</published>
+  <updated>2024-01-02T08:00:00+00:00</updated>
+  <media:group>
+   <media:title>First Video (Full Talk)</media:title>
+   <media:content url="https://www.youtube.com/v/abc123?version=3" type="application/x-shockwave-flash" width="640" height="390"/>
+   <media:thumbnail url="https://i1.ytimg.com/vi/abc123/hqdefault.jpg" width="480" height="360"/>
+   <media:description>All about the first video.</media:description>
+  </media:group>
+ </entry>
+ <entry>
+  <id>yt:video:def456</id>
+  <yt:videoId>def456</yt:videoId>
+  <title>Second Video</title>
+  <link rel="alternate" href="https://www.youtube.com/watch?v=def456"/>
+  <published>2024-01-05T12:00:00+00:00</published>
+  <media:group>
+   <media:title>Second Video</media:title>
+   <media:description>The sequel.</media:description>
+  </media:group>
+ </entry>
+</feed>`
+
+func TestYouTubeFeed(t *testing.T) {
+	rss, err := parseRSS([]byte(mockYouTubeFeed))
+	if err != nil {
+		t.Fatalf("Failed to parse YouTube feed: %v", err)
+	}
+	ical, err := rssToICal(rss, defaultCalendarOptions())
+	if err != nil {
+		t.Fatalf("Failed to convert RSS to iCal: %v", err)
+	}
+	ical = unfoldICal(ical)
+
+	if n := strings.Count(ical, "BEGIN:VEVENT"); n != 2 {
+		t.Errorf("Expected 2 events, got %d", n)
+	}
+	for _, want := range []string{
+		"UID:yt:video:abc123\r\n",
+		"SUMMARY:First Video (Full Talk)\r\n",
+		"DESCRIPTION:All about the first video.\r\n",
+		"URL:https://www.youtube.com/watch?v=abc123\r\n",
+		"LOCATION:https://www.youtube.com/watch?v=abc123\r\n",
+		"DTSTART:20240101T120000Z\r\n",
+		"IMAGE;VALUE=URI:https://i1.ytimg.com/vi/abc123/hqdefault.jpg\r\n",
+		"URL:https://www.youtube.com/watch?v=def456\r\n",
+	} {
+		if !strings.Contains(ical, want) {
+			t.Errorf("Expected %q in output, got: %s", want, ical)
+		}
+	}
+}